@@ -5,24 +5,58 @@ cmd="web-indexer"
 
 # Check each expected environment variable and append it to the command if set
 [[ -n "$BASE_URL" ]] && cmd="$cmd --base-url \"$BASE_URL\""
+[[ -n "$CHECKSUMS" ]] && cmd="$cmd --checksums \"$CHECKSUMS\""
+[[ -n "$CHECKSUM_CACHE" ]] && cmd="$cmd --checksum-cache \"$CHECKSUM_CACHE\""
+[[ "$CHECKSUM_FILES" == "true" ]] && cmd="$cmd --checksum-files"
+[[ -n "$COMPANIONS" ]] && cmd="$cmd --companions \"$COMPANIONS\""
 [[ -n "$CONFIG" ]] && cmd="$cmd --config \"$CONFIG\""
 [[ -n "$DATE_FORMAT" ]] && cmd="$cmd --date-format \"$DATE_FORMAT\""
+[[ "$DESCRIPTIONS" == "true" ]] && cmd="$cmd --descriptions"
+[[ "$DETAIL_PAGES" == "true" ]] && cmd="$cmd --detail-pages"
 [[ "$DIRS_FIRST" == "true" ]] && cmd="$cmd --dirs-first"
+[[ "$DIR_STATS" == "true" ]] && cmd="$cmd --dir-stats"
+[[ -n "$FEED" ]] && cmd="$cmd --feed \"$FEED\""
+[[ -n "$FEED_FILE" ]] && cmd="$cmd --feed-file \"$FEED_FILE\""
+[[ -n "$FEED_ITEMS" ]] && cmd="$cmd --feed-items \"$FEED_ITEMS\""
+[[ -n "$FEED_SCOPE" ]] && cmd="$cmd --feed-scope \"$FEED_SCOPE\""
+[[ -n "$FORMATS" ]] && cmd="$cmd --formats \"$FORMATS\""
+[[ "$GALLERY" == "true" ]] && cmd="$cmd --gallery"
+[[ -n "$HEADER_FILES" ]] && cmd="$cmd --header-files \"$HEADER_FILES\""
 [[ -n "$INDEX_FILE" ]] && cmd="$cmd --index-file \"$INDEX_FILE\""
+[[ "$KEEP_GOING" == "true" ]] && cmd="$cmd --keep-going"
+[[ -n "$LAYOUT" ]] && cmd="$cmd --layout \"$LAYOUT\""
 [[ "$LINK_TO_INDEX" == "true" ]] && cmd="$cmd --link-to-index"
 [[ -n "$LOG_LEVEL" ]] && cmd="$cmd --log-level \"$LOG_LEVEL\""
+[[ -n "$MAX_RETRIES" ]] && cmd="$cmd --max-retries \"$MAX_RETRIES\""
+[[ "$MEDIA_METADATA" == "true" ]] && cmd="$cmd --media-metadata"
+[[ "$MEDIA_PLAYERS" == "true" ]] && cmd="$cmd --media-players"
 [[ "$MINIFY" == "true" ]] && cmd="$cmd --minify"
 [[ -n "$NOINDEX_FILES" ]] && cmd="$cmd --noindex-files \"$NOINDEX_FILES\""
 [[ -n "$SKIPINDEX_FILES" ]] && cmd="$cmd --skipindex-files \"$SKIPINDEX_FILES\""
 [[ -n "$ORDER" ]] && cmd="$cmd --order \"$ORDER\""
+[[ -n "$PAGE_SIZE" ]] && cmd="$cmd --page-size \"$PAGE_SIZE\""
+[[ -n "$README_FILES" ]] && cmd="$cmd --readme-files \"$README_FILES\""
 [[ "$RECURSIVE" == "true" ]] && cmd="$cmd --recursive"
+[[ -n "$REPORT" ]] && cmd="$cmd --report \"$REPORT\""
+[[ -n "$REQUEST_TIMEOUT" ]] && cmd="$cmd --request-timeout \"$REQUEST_TIMEOUT\""
+[[ -n "$RETRY_DELAY" ]] && cmd="$cmd --retry-delay \"$RETRY_DELAY\""
+[[ "$SEARCH" == "true" ]] && cmd="$cmd --search"
+[[ "$SITEMAP" == "true" ]] && cmd="$cmd --sitemap"
+[[ "$SITEMAP_FILES" == "true" ]] && cmd="$cmd --sitemap-files"
 [[ -n "$SKIP" ]] && cmd="$cmd --skip \"$SKIP\""
+[[ "$SNIFF_CONTENT" == "true" ]] && cmd="$cmd --sniff-content"
 [[ -n "$SORT" ]] && cmd="$cmd --sort \"$SORT\""
 [[ -n "$SOURCE" ]] && cmd="$cmd --source \"$SOURCE\""
 [[ -n "$TARGET" ]] && cmd="$cmd --target \"$TARGET\""
 [[ -n "$TEMPLATE" ]] && cmd="$cmd --template \"$TEMPLATE\""
+[[ -n "$THUMBNAIL_SIZE" ]] && cmd="$cmd --thumbnail-size \"$THUMBNAIL_SIZE\""
+[[ -n "$TIMEOUT" ]] && cmd="$cmd --timeout \"$TIMEOUT\""
 [[ -n "$THEME" ]] && cmd="$cmd --theme \"$THEME\""
 [[ -n "$TITLE" ]] && cmd="$cmd --title \"$TITLE\""
+[[ "$TREE" == "true" ]] && cmd="$cmd --tree"
+[[ "$VERIFY_COMPANIONS" == "true" ]] && cmd="$cmd --verify-companions"
+[[ "$VIEWER" == "true" ]] && cmd="$cmd --viewer"
+[[ -n "$VIEWER_MAX_SIZE" ]] && cmd="$cmd --viewer-max-size \"$VIEWER_MAX_SIZE\""
 
 # Debug: Print the command to be executed
 echo "Executing command: $cmd"
@@ -0,0 +1,302 @@
+package webindexer
+
+import (
+	"context"
+	"crypto/md5"  // #nosec G501 -- offered for compatibility, not security
+	"crypto/sha1" // #nosec G505 -- offered for compatibility, not security
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// ChecksumAlgorithm is a hash algorithm used to checksum files.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumSHA1   ChecksumAlgorithm = "sha1"
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumSHA512 ChecksumAlgorithm = "sha512"
+)
+
+// newHash returns a new hash for the algorithm, or nil if it is unknown.
+func (a ChecksumAlgorithm) newHash() hash.Hash {
+	switch a {
+	case ChecksumMD5:
+		return md5.New() // #nosec G401
+	case ChecksumSHA1:
+		return sha1.New() // #nosec G401
+	case ChecksumSHA256:
+		return sha256.New()
+	case ChecksumSHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// sumsFile returns the name of the checksum file for the algorithm, in the
+// style of the coreutils tools, such as SHA256SUMS.
+func (a ChecksumAlgorithm) sumsFile() string {
+	return strings.ToUpper(string(a)) + "SUMS"
+}
+
+// Checksum is the checksum of a file with one algorithm.
+type Checksum struct {
+	Algorithm ChecksumAlgorithm
+	// Sum is the hex encoded checksum.
+	Sum string
+}
+
+// Checksum returns the item's checksum with the given algorithm, or an empty
+// string if it wasn't computed.
+func (i Item) Checksum(algorithm string) string {
+	for _, c := range i.Checksums {
+		if string(c.Algorithm) == algorithm {
+			return c.Sum
+		}
+	}
+
+	return ""
+}
+
+// ChecksumValues returns the configured checksum algorithms.
+func (c Config) ChecksumValues() []ChecksumAlgorithm {
+	algorithms := make([]ChecksumAlgorithm, 0, len(c.Checksums))
+	for _, a := range c.Checksums {
+		algorithms = append(algorithms, ChecksumAlgorithm(strings.ToLower(strings.TrimSpace(a))))
+	}
+
+	return algorithms
+}
+
+// validateChecksums checks that the checksum algorithms are known.
+func (c Config) validateChecksums() error {
+	for _, a := range c.ChecksumValues() {
+		if a.newHash() == nil {
+			return fmt.Errorf("checksums must be one or more of: md5, sha1, sha256, sha512")
+		}
+	}
+
+	if c.ChecksumFiles && len(c.Checksums) == 0 {
+		return fmt.Errorf("checksums are required for checksum_files")
+	}
+
+	return nil
+}
+
+// isChecksumFile reports whether name is a generated checksum file.
+func (c Config) isChecksumFile(name string) bool {
+	if !c.ChecksumFiles {
+		return false
+	}
+
+	for _, a := range c.ChecksumValues() {
+		if shouldSkip(name, a.sumsFile(), nil) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checksumEntry is a file's cached checksums, valid while the file's size
+// and modification time are unchanged.
+type checksumEntry struct {
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"mod_time"`
+	Sums    map[string]string `json:"sums"`
+}
+
+// checksumCache is a local file of checksums computed by earlier runs, so
+// that unchanged files aren't hashed again.
+type checksumCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]checksumEntry
+	dirty   bool
+}
+
+// loadChecksumCache loads the checksum cache at path. A missing cache is
+// created when it is saved.
+func loadChecksumCache(path string) (*checksumCache, error) {
+	cache := &checksumCache{path: path, entries: make(map[string]checksumEntry)}
+
+	content, err := os.ReadFile(path) // #nosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checksum cache %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(content, &cache.entries); err != nil {
+		return nil, fmt.Errorf("unable to parse checksum cache %s: %w", path, err)
+	}
+
+	return cache, nil
+}
+
+// get returns the cached checksums of a file, if they were computed for the
+// same size and modification time and include every algorithm.
+func (c *checksumCache) get(key string, size int64, modTime time.Time, algorithms []ChecksumAlgorithm) []Checksum {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.Size != size || !entry.ModTime.Equal(modTime) {
+		return nil
+	}
+
+	sums := make([]Checksum, 0, len(algorithms))
+	for _, a := range algorithms {
+		sum, ok := entry.Sums[string(a)]
+		if !ok {
+			return nil
+		}
+		sums = append(sums, Checksum{Algorithm: a, Sum: sum})
+	}
+
+	return sums
+}
+
+// put caches the checksums of a file.
+func (c *checksumCache) put(key string, size int64, modTime time.Time, sums []Checksum) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := checksumEntry{Size: size, ModTime: modTime, Sums: make(map[string]string, len(sums))}
+	for _, s := range sums {
+		entry.Sums[string(s.Algorithm)] = s.Sum
+	}
+	c.entries[key] = entry
+	c.dirty = true
+}
+
+// save writes the cache back to its file if any checksums were added.
+func (c *checksumCache) save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	content, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("unable to encode checksum cache: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, content, 0o600); err != nil {
+		return fmt.Errorf("unable to write checksum cache %s: %w", c.path, err)
+	}
+	c.dirty = false
+
+	return nil
+}
+
+// checksums returns the checksums of a file with the configured algorithms,
+// from the cache if the file is unchanged. The open function returns the
+// content of the file. A file that can't be read is logged and gets no
+// checksums, rather than failing its listing. Nothing is computed when
+// listing for stats.
+func (c Config) checksums(
+	cache *checksumCache, key string, size int64, modTime time.Time, open func() (io.ReadCloser, error),
+) []Checksum {
+	algorithms := c.ChecksumValues()
+	if len(algorithms) == 0 || c.statsOnly {
+		return nil
+	}
+
+	if sums := cache.get(key, size, modTime, algorithms); sums != nil {
+		return sums
+	}
+
+	log.Debugf("Computing checksums of %s", key)
+	hashes := make([]hash.Hash, 0, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, a := range algorithms {
+		h := a.newHash()
+		hashes = append(hashes, h)
+		writers = append(writers, h)
+	}
+
+	reader, err := open()
+	if err != nil {
+		log.Warnf("Unable to open %s for its checksums: %v", key, err)
+		return nil
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		log.Warnf("Unable to read %s for its checksums: %v", key, err)
+		return nil
+	}
+
+	sums := make([]Checksum, 0, len(algorithms))
+	for n, a := range algorithms {
+		sums = append(sums, Checksum{Algorithm: a, Sum: hex.EncodeToString(hashes[n].Sum(nil))})
+	}
+	cache.put(key, size, modTime, sums)
+
+	return sums
+}
+
+// renderSums renders a checksum file for the files of a listing, in the
+// format read by tools such as sha256sum --check.
+func renderSums(items []Item, algorithm ChecksumAlgorithm) []byte {
+	var b strings.Builder
+	for _, item := range items {
+		if sum := item.Checksum(string(algorithm)); sum != "" && !item.IsDir {
+			fmt.Fprintf(&b, "%s  %s\n", sum, item.Name)
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// writeChecksumFiles writes a checksum file for each algorithm to the
+// directory of a listing, if enabled and any of its files were checksummed.
+func (i Indexer) writeChecksumFiles(ctx context.Context, r *run, data Data) error {
+	if !i.Cfg.ChecksumFiles {
+		return nil
+	}
+
+	for _, a := range i.Cfg.ChecksumValues() {
+		content := renderSums(data.Items, a)
+		if len(content) == 0 {
+			continue
+		}
+
+		o := output{kind: outputFile, file: a.sumsFile(), contentType: "text/plain; charset=utf-8"}
+		if err := i.writeOutput(ctx, r, o, data, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
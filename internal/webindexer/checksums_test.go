@@ -0,0 +1,164 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	helloMD5    = "5d41402abc4b2a76b9719d911017c592"
+)
+
+func openString(s string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(s)), nil
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	cfg := Config{Checksums: []string{"SHA256", "md5"}}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := &checksumCache{entries: make(map[string]checksumEntry)}
+
+	sums := cfg.checksums(cache, "/files/hello.txt", 5, modTime, openString("hello"))
+	assert.Equal(t, []Checksum{
+		{Algorithm: ChecksumSHA256, Sum: helloSHA256},
+		{Algorithm: ChecksumMD5, Sum: helloMD5},
+	}, sums)
+	assert.Equal(t, helloMD5, Item{Checksums: sums}.Checksum("md5"))
+	assert.Empty(t, Item{Checksums: sums}.Checksum("sha1"))
+
+	// An unchanged file isn't read again
+	failOpen := func() (io.ReadCloser, error) {
+		return nil, errors.New("should not be opened")
+	}
+	cached := cfg.checksums(cache, "/files/hello.txt", 5, modTime, failOpen)
+	assert.Equal(t, sums, cached)
+
+	// A modified file is hashed again, and gets no checksums if it can't be
+	// read
+	assert.Nil(t, cfg.checksums(cache, "/files/hello.txt", 5, modTime.Add(time.Second), failOpen))
+
+	// As is a file cached without all of the configured algorithms
+	cfg.Checksums = append(cfg.Checksums, "sha1")
+	assert.Nil(t, cfg.checksums(cache, "/files/hello.txt", 5, modTime, failOpen))
+
+	// Nothing is computed without any algorithms
+	assert.Nil(t, Config{}.checksums(nil, "/files/hello.txt", 5, modTime, failOpen))
+}
+
+func TestChecksumCache_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checksums.json")
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cache, err := loadChecksumCache(path)
+	require.NoError(t, err)
+	cache.put("s3://bucket/hello.txt", 5, modTime, []Checksum{{Algorithm: ChecksumSHA256, Sum: helloSHA256}})
+	require.NoError(t, cache.save())
+
+	loaded, err := loadChecksumCache(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Checksum{{Algorithm: ChecksumSHA256, Sum: helloSHA256}},
+		loaded.get("s3://bucket/hello.txt", 5, modTime, []ChecksumAlgorithm{ChecksumSHA256}))
+	assert.Nil(t, loaded.get("s3://bucket/hello.txt", 6, modTime, []ChecksumAlgorithm{ChecksumSHA256}))
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	_, err = loadChecksumCache(path)
+	require.Error(t, err)
+}
+
+func TestRenderSums(t *testing.T) {
+	items := []Item{
+		{Name: "dir/", IsDir: true},
+		{Name: "hello.txt", Checksums: []Checksum{{Algorithm: ChecksumSHA256, Sum: helloSHA256}}},
+		{Name: "other.txt"},
+	}
+
+	assert.Equal(t, helloSHA256+"  hello.txt\n", string(renderSums(items, ChecksumSHA256)))
+	assert.Empty(t, renderSums(items, ChecksumMD5))
+}
+
+func TestS3BackendList_Checksums(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			Source:    "s3://test-bucket",
+			IndexFile: "index.html",
+			Checksums: []string{"sha256"},
+		},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("files/hello.txt"), Size: aws.Int64(5), LastModified: aws.Time(time.Now())},
+		},
+	}, nil)
+	mockSvc.On("GetObjectWithContext", &s3.GetObjectInput{
+		Bucket: aws.String("test-bucket"),
+		Key:    aws.String("files/hello.txt"),
+	}).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("hello"))}, nil)
+
+	listing, err := backend.List(context.Background(), "files/")
+	require.NoError(t, err)
+	require.Len(t, listing.Items, 1)
+	assert.Equal(t, helloSHA256, listing.Items[0].Checksum("sha256"))
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGenerate_Checksums(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "hello.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(sourceDir, "sub"), 0o755))
+
+	cfg := Config{
+		Source:        sourceDir,
+		Target:        sourceDir,
+		SortBy:        "name",
+		Order:         "asc",
+		IndexFile:     "index.html",
+		BasePath:      sourceDir,
+		DateFormat:    "2006-01-02",
+		Checksums:     []string{"sha256"},
+		ChecksumFiles: true,
+		ChecksumCache: filepath.Join(t.TempDir(), "checksums.json"),
+	}
+	indexer := Indexer{Cfg: cfg, BackendSetup: defaultBackendSetup{}}
+	require.NoError(t, indexer.BackendSetup.Setup(&indexer))
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	sums, err := os.ReadFile(filepath.Join(sourceDir, "SHA256SUMS"))
+	require.NoError(t, err)
+	assert.Equal(t, helloSHA256+"  hello.txt\n", string(sums))
+
+	content, err := os.ReadFile(filepath.Join(sourceDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `data-copy="`+helloSHA256+`"`)
+	assert.Contains(t, string(content), "navigator.clipboard")
+	assert.NotContains(t, string(content), "SHA256SUMS")
+
+	// The cache is saved for the next run
+	assert.FileExists(t, cfg.ChecksumCache)
+
+	// A second run leaves the checksum file as it is
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.IndexesUnchanged)
+	assert.Equal(t, 1, result.FilesUnchanged)
+}
@@ -0,0 +1,184 @@
+package webindexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Companion is a file accompanying another one, such as its signature or
+// checksum, shown in the row of the file it accompanies.
+type Companion struct {
+	Name string
+	URL  string
+	// Label is the suffix of the companion without its leading dot, such as
+	// "asc" for foo.tar.gz.asc.
+	Label     string
+	Size      string
+	SizeBytes int64
+}
+
+// validateCompanions checks that checksums are computed when companion
+// checksum files are to be verified.
+func (c Config) validateCompanions() error {
+	if c.VerifyCompanions && len(c.Checksums) == 0 {
+		return fmt.Errorf("checksums are required for verify_companions")
+	}
+
+	return nil
+}
+
+// companionSuffix returns the configured companion suffix of name whose
+// primary file is in the listing, if any.
+func (c Config) companionSuffix(name string, files map[string]bool) (string, bool) {
+	for _, suffix := range c.Companions {
+		primary, ok := strings.CutSuffix(name, suffix)
+		if ok && primary != "" && files[primary] {
+			return suffix, true
+		}
+	}
+
+	return "", false
+}
+
+// groupCompanions folds companion files into the items they accompany. A
+// companion of a companion, such as foo.tar.gz.sha256.asc, is folded into
+// the primary file too. With verification enabled, checksum companions are
+// read and compared to the checksums of their primary file. The read
+// function returns the content of a file in the directory.
+func (c Config) groupCompanions(dir string, items []Item, read func(name string) ([]byte, error)) []Item {
+	if len(c.Companions) == 0 {
+		return items
+	}
+
+	files := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !item.IsDir {
+			files[item.Name] = true
+		}
+	}
+
+	// Find the primary file of each companion, following chains of
+	// companions back to the first file that isn't one.
+	primaries := make(map[string]string)
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		primary := item.Name
+		for {
+			suffix, ok := c.companionSuffix(primary, files)
+			if !ok {
+				break
+			}
+			primary = strings.TrimSuffix(primary, suffix)
+		}
+		if primary != item.Name {
+			primaries[item.Name] = primary
+		}
+	}
+
+	companions := make(map[string][]Item)
+	grouped := make([]Item, 0, len(items)-len(primaries))
+	for _, item := range items {
+		if primary, ok := primaries[item.Name]; ok {
+			companions[primary] = append(companions[primary], item)
+			continue
+		}
+		grouped = append(grouped, item)
+	}
+
+	for n := range grouped {
+		item := &grouped[n]
+		group := companions[item.Name]
+		sort.Slice(group, func(a, b int) bool { return group[a].Name < group[b].Name })
+		for _, companion := range group {
+			item.Companions = append(item.Companions, Companion{
+				Name:      companion.Name,
+				Label:     strings.TrimPrefix(strings.TrimPrefix(companion.Name, item.Name), "."),
+				Size:      companion.Size,
+				SizeBytes: companion.SizeBytes,
+			})
+		}
+
+		if c.VerifyCompanions && !c.statsOnly {
+			c.verifyCompanions(dir, item, group, read)
+		}
+	}
+
+	return grouped
+}
+
+// verifyCompanions compares the checksums of an item to those read from its
+// checksum companions, such as foo.tar.gz.sha256, for each algorithm that
+// was computed. An item with a companion that can't be read or has no
+// checksum for it is left unverified.
+func (c Config) verifyCompanions(dir string, item *Item, companions []Item, read func(string) ([]byte, error)) {
+	for _, companion := range companions {
+		suffix := strings.TrimPrefix(companion.Name, item.Name+".")
+		sum := item.Checksum(suffix)
+		if sum == "" {
+			continue
+		}
+		if companion.SizeBytes > maxNoteSize {
+			log.Warnf("Not verifying %s in %s as it is larger than %s", companion.Name, dir,
+				humanizeBytes(maxNoteSize))
+			continue
+		}
+
+		content, err := read(companion.Name)
+		if err != nil {
+			log.Warnf("Unable to read %s in %s to verify %s: %v", companion.Name, dir, item.Name, err)
+			item.Verified = false
+
+			return
+		}
+
+		expected, ok := parseChecksumFile(content, item.Name)
+		if !ok {
+			log.Warnf("No checksum for %s found in %s in %s", item.Name, companion.Name, dir)
+			item.Verified = false
+
+			return
+		}
+
+		if !strings.EqualFold(expected, sum) {
+			log.Warnf("Checksum mismatch for %s in %s: %s has %s but the file has %s",
+				item.Name, dir, companion.Name, expected, sum)
+			item.ChecksumMismatch = true
+			item.Verified = false
+
+			return
+		}
+		item.Verified = true
+	}
+}
+
+// parseChecksumFile returns the checksum of the named file from the content
+// of a checksum file. It may hold just the checksum, or lines of checksums
+// and file names as written by tools such as sha256sum.
+func parseChecksumFile(content []byte, name string) (string, bool) {
+	var first string
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			if first == "" {
+				first = fields[0]
+			}
+			continue
+		}
+
+		// Binary mode entries have an asterisk before the file name.
+		file := strings.TrimPrefix(fields[len(fields)-1], "*")
+		if file == name || strings.HasSuffix(file, "/"+name) {
+			return fields[0], true
+		}
+	}
+
+	return first, first != ""
+}
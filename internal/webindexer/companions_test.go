@@ -0,0 +1,164 @@
+package webindexer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupCompanions(t *testing.T) {
+	items := []Item{
+		{Name: "app.tar.gz", Size: "1 KB"},
+		{Name: "app.tar.gz.asc", Size: "1 KB", SizeBytes: 800},
+		{Name: "app.tar.gz.sha256"},
+		{Name: "app.tar.gz.sha256.asc"},
+		{Name: "orphan.sig"},
+		{Name: "docs.asc/", IsDir: true},
+		{Name: "docs/", IsDir: true},
+	}
+	read := func(string) ([]byte, error) {
+		t.Fatal("companions should only be read when verifying")
+		return nil, nil
+	}
+
+	// Nothing is grouped without companion suffixes
+	grouped := Config{}.groupCompanions("/dir", items, read)
+	assert.Equal(t, items, grouped)
+
+	cfg := Config{Companions: []string{".sha256", ".asc", ".sig"}}
+	grouped = cfg.groupCompanions("/dir", items, read)
+	assert.Equal(t, []Item{
+		{Name: "app.tar.gz", Size: "1 KB", Companions: []Companion{
+			{Name: "app.tar.gz.asc", Label: "asc", Size: "1 KB", SizeBytes: 800},
+			{Name: "app.tar.gz.sha256", Label: "sha256"},
+			{Name: "app.tar.gz.sha256.asc", Label: "sha256.asc"},
+		}},
+		{Name: "orphan.sig"},
+		{Name: "docs.asc/", IsDir: true},
+		{Name: "docs/", IsDir: true},
+	}, grouped)
+}
+
+func TestGroupCompanions_Verify(t *testing.T) {
+	files := map[string]string{
+		"good.tar.gz.sha256": helloSHA256 + "  good.tar.gz\n",
+		"bad.tar.gz.sha256":  "0000000000000000000000000000000000000000000000000000000000000000\n",
+	}
+	read := func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	}
+	sums := []Checksum{{Algorithm: ChecksumSHA256, Sum: helloSHA256}}
+	items := []Item{
+		{Name: "bad.tar.gz", Checksums: sums},
+		{Name: "bad.tar.gz.sha256"},
+		{Name: "good.tar.gz", Checksums: sums},
+		{Name: "good.tar.gz.sha256"},
+		{Name: "unsummed.tar.gz"},
+		{Name: "unsummed.tar.gz.sha256"},
+	}
+
+	cfg := Config{Companions: []string{".sha256"}, Checksums: []string{"sha256"}, VerifyCompanions: true}
+	grouped := cfg.groupCompanions("/dir", items, read)
+	require.Len(t, grouped, 3)
+	assert.True(t, grouped[0].ChecksumMismatch)
+	assert.False(t, grouped[0].Verified)
+	assert.True(t, grouped[1].Verified)
+	assert.False(t, grouped[1].ChecksumMismatch)
+	assert.False(t, grouped[2].Verified)
+	assert.False(t, grouped[2].ChecksumMismatch)
+}
+
+func TestGroupCompanions_VerifyUnreadable(t *testing.T) {
+	files := map[string]string{
+		"app.tar.gz.sha256":  helloSHA256 + "  app.tar.gz\n",
+		"app.tar.gz.sha512":  "",
+		"lib.tar.gz.sha256":  "",
+		"docs.tar.gz.sha256": helloSHA256 + "\n",
+	}
+	read := func(name string) ([]byte, error) {
+		if name == "docs.tar.gz.sha256" {
+			return nil, errors.New("permission denied")
+		}
+		return []byte(files[name]), nil
+	}
+	sums := []Checksum{{Algorithm: ChecksumSHA256, Sum: helloSHA256}, {Algorithm: ChecksumSHA512, Sum: "abc"}}
+	items := []Item{
+		{Name: "app.tar.gz", Checksums: sums},
+		{Name: "app.tar.gz.sha256"},
+		{Name: "app.tar.gz.sha512"},
+		{Name: "docs.tar.gz", Checksums: sums},
+		{Name: "docs.tar.gz.sha256"},
+		{Name: "lib.tar.gz", Checksums: sums},
+		{Name: "lib.tar.gz.sha256"},
+	}
+
+	// Companions that can't be read or parsed leave their file unverified,
+	// even if another companion matched
+	cfg := Config{
+		Companions:       []string{".sha256", ".sha512"},
+		Checksums:        []string{"sha256", "sha512"},
+		VerifyCompanions: true,
+	}
+	grouped := cfg.groupCompanions("/dir", items, read)
+	require.Len(t, grouped, 3)
+	for _, item := range grouped {
+		assert.False(t, item.Verified, item.Name)
+		assert.False(t, item.ChecksumMismatch, item.Name)
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantOK  bool
+	}{
+		{name: "bare checksum", content: "abc123\n", want: "abc123", wantOK: true},
+		{name: "single entry", content: "abc123  app.tar.gz\n", want: "abc123", wantOK: true},
+		{name: "binary mode", content: "abc123 *app.tar.gz\n", want: "abc123", wantOK: true},
+		{name: "with path", content: "abc123  dist/app.tar.gz\n", want: "abc123", wantOK: true},
+		{name: "several entries", content: "def456  other.tar.gz\nabc123  app.tar.gz\n", want: "abc123", wantOK: true},
+		{name: "other file", content: "def456  other.tar.gz\n", wantOK: false},
+		{name: "empty", content: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseChecksumFile([]byte(tt.content), "app.tar.gz")
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGenerate_Companions(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	files := map[string]string{
+		"app.tar.gz":        "hello",
+		"app.tar.gz.sha256": helloSHA256 + "  app.tar.gz\n",
+		"app.tar.gz.asc":    "signature",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Companions = []string{".sha256", ".asc"}
+	cfg.Checksums = []string{"sha256"}
+	cfg.VerifyCompanions = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<a class="companion" href="app.tar.gz.asc"`)
+		assert.Contains(t, index, `>sha256</a>`)
+		assert.Contains(t, index, `<span class="verified" title="Checksum verified">`)
+		assert.NotContains(t, index, `<a href="app.tar.gz.asc">`)
+	})
+}
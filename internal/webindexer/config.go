@@ -2,31 +2,75 @@ package webindexer
 
 import (
 	"fmt"
+	"time"
 )
 
 type Config struct {
-	BaseURL        string   `yaml:"base_url"      mapstructure:"base_url"`
-	DateFormat     string   `yaml:"date_format"   mapstructure:"date_format"`
-	DirsFirst      bool     `yaml:"dirs_first"    mapstructure:"dirs_first"`
-	IndexFile      string   `yaml:"index_file"    mapstructure:"index_file"`
-	LinkToIndexes  bool     `yaml:"link_to_index" mapstructure:"link_to_index"`
-	LogLevel       string   `yaml:"log_level"     mapstructure:"log_level"`
-	LogFile        string   `yaml:"log_file"      mapstructure:"log_file"`
-	Minify         bool     `yaml:"minify"        mapstructure:"minify"`
-	NoIndexFiles   []string `yaml:"noindex_files" mapstructure:"noindex_files"`
-	SkipIndexFiles []string `yaml:"skipindex_files" mapstructure:"skipindex_files"`
-	Order          string   `yaml:"order"         mapstructure:"order"`
-	Quiet          bool     `yaml:"quiet"         mapstructure:"quiet"`
-	Recursive      bool     `yaml:"recursive"     mapstructure:"recursive"`
-	Skips          []string `yaml:"skips"         mapstructure:"skips"`
-	SortBy         string   `yaml:"sort_by"       mapstructure:"sort_by"`
-	Source         string   `yaml:"source"        mapstructure:"source"`
-	Target         string   `yaml:"target"        mapstructure:"target"`
-	Template       string   `yaml:"template"      mapstructure:"template"`
-	Theme          string   `yaml:"theme"         mapstructure:"theme"`
-	Title          string   `yaml:"title"         mapstructure:"title"`
-	CfgFile        string   `yaml:"-"`
-	BasePath       string   `yaml:"-"`
+	BaseURL          string              `yaml:"base_url"      mapstructure:"base_url"`
+	ChecksumCache    string              `yaml:"checksum_cache" mapstructure:"checksum_cache"`
+	ChecksumFiles    bool                `yaml:"checksum_files" mapstructure:"checksum_files"`
+	Checksums        []string            `yaml:"checksums"     mapstructure:"checksums"`
+	Companions       []string            `yaml:"companions"    mapstructure:"companions"`
+	DateFormat       string              `yaml:"date_format"   mapstructure:"date_format"`
+	DetailPages      bool                `yaml:"detail_pages"  mapstructure:"detail_pages"`
+	Descriptions     bool                `yaml:"descriptions"  mapstructure:"descriptions"`
+	DirStats         bool                `yaml:"dir_stats"     mapstructure:"dir_stats"`
+	DirsFirst        bool                `yaml:"dirs_first"    mapstructure:"dirs_first"`
+	Feed             string              `yaml:"feed"          mapstructure:"feed"`
+	FeedFile         string              `yaml:"feed_file"     mapstructure:"feed_file"`
+	FeedItems        int                 `yaml:"feed_items"    mapstructure:"feed_items"`
+	FeedScope        string              `yaml:"feed_scope"    mapstructure:"feed_scope"`
+	FileTypes        map[string]FileType `yaml:"file_types"    mapstructure:"file_types"`
+	Formats          []string            `yaml:"formats"       mapstructure:"formats"`
+	Gallery          bool                `yaml:"gallery"       mapstructure:"gallery"`
+	HeaderFiles      []string            `yaml:"header_files"  mapstructure:"header_files"`
+	IndexFile        string              `yaml:"index_file"    mapstructure:"index_file"`
+	KeepGoing        bool                `yaml:"keep_going"    mapstructure:"keep_going"`
+	Layout           string              `yaml:"layout"        mapstructure:"layout"`
+	LinkToIndexes    bool                `yaml:"link_to_index" mapstructure:"link_to_index"`
+	LogLevel         string              `yaml:"log_level"     mapstructure:"log_level"`
+	LogFile          string              `yaml:"log_file"      mapstructure:"log_file"`
+	MaxRetries       int                 `yaml:"max_retries"   mapstructure:"max_retries"`
+	MediaMetadata    bool                `yaml:"media_metadata" mapstructure:"media_metadata"`
+	MediaPlayers     bool                `yaml:"media_players" mapstructure:"media_players"`
+	Minify           bool                `yaml:"minify"        mapstructure:"minify"`
+	NoIndexFiles     []string            `yaml:"noindex_files" mapstructure:"noindex_files"`
+	SkipIndexFiles   []string            `yaml:"skipindex_files" mapstructure:"skipindex_files"`
+	Order            string              `yaml:"order"         mapstructure:"order"`
+	Outputs          []OutputConfig      `yaml:"outputs"       mapstructure:"outputs"`
+	PageSize         int                 `yaml:"page_size"     mapstructure:"page_size"`
+	Quiet            bool                `yaml:"quiet"         mapstructure:"quiet"`
+	ReadmeFiles      []string            `yaml:"readme_files"  mapstructure:"readme_files"`
+	Recursive        bool                `yaml:"recursive"     mapstructure:"recursive"`
+	Report           string              `yaml:"report"        mapstructure:"report"`
+	RequestTimeout   time.Duration       `yaml:"request_timeout" mapstructure:"request_timeout"`
+	RetryDelay       time.Duration       `yaml:"retry_delay"   mapstructure:"retry_delay"`
+	Search           bool                `yaml:"search"        mapstructure:"search"`
+	Sitemap          bool                `yaml:"sitemap"       mapstructure:"sitemap"`
+	SitemapFiles     bool                `yaml:"sitemap_files" mapstructure:"sitemap_files"`
+	SniffContent     bool                `yaml:"sniff_content" mapstructure:"sniff_content"`
+	Skips            []string            `yaml:"skips"         mapstructure:"skips"`
+	SortBy           string              `yaml:"sort_by"       mapstructure:"sort_by"`
+	Source           string              `yaml:"source"        mapstructure:"source"`
+	Target           string              `yaml:"target"        mapstructure:"target"`
+	Template         string              `yaml:"template"      mapstructure:"template"`
+	Theme            string              `yaml:"theme"         mapstructure:"theme"`
+	ThumbnailSize    int                 `yaml:"thumbnail_size" mapstructure:"thumbnail_size"`
+	Timeout          time.Duration       `yaml:"timeout"       mapstructure:"timeout"`
+	Title            string              `yaml:"title"         mapstructure:"title"`
+	Tree             bool                `yaml:"tree"          mapstructure:"tree"`
+	VerifyCompanions bool                `yaml:"verify_companions" mapstructure:"verify_companions"`
+	Viewer           bool                `yaml:"viewer"        mapstructure:"viewer"`
+	ViewerMaxSize    int64               `yaml:"viewer_max_size" mapstructure:"viewer_max_size"`
+	CfgFile          string              `yaml:"-"`
+	BasePath         string              `yaml:"-"`
+
+	// fileTypeExts holds the extensions of FileTypes, longest first, so they
+	// aren't sorted for every item. It's set by New.
+	fileTypeExts []string
+	// statsOnly is set on the config of the sources walked for directory
+	// stats, which only need the names, sizes and times of the items.
+	statsOnly bool
 }
 
 type SortBy string
@@ -107,5 +151,65 @@ func (c Config) Validate() error {
 		return fmt.Errorf("order must be one of: asc, desc")
 	}
 
+	if err := c.validateOutputs(); err != nil {
+		return err
+	}
+
+	if err := c.validateFeed(); err != nil {
+		return err
+	}
+
+	if err := c.validateChecksums(); err != nil {
+		return err
+	}
+
+	if err := c.validateCompanions(); err != nil {
+		return err
+	}
+
+	if err := c.validateViewer(); err != nil {
+		return err
+	}
+
+	if err := c.validateGallery(); err != nil {
+		return err
+	}
+
+	if err := c.validateMedia(); err != nil {
+		return err
+	}
+
+	if err := c.validateLayout(); err != nil {
+		return err
+	}
+
+	if err := c.validateFileTypes(); err != nil {
+		return err
+	}
+
+	if c.Sitemap && c.BaseURL == "" {
+		return fmt.Errorf("base_url is required for sitemaps")
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("request_timeout must not be negative")
+	}
+
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+
+	if c.RetryDelay < 0 {
+		return fmt.Errorf("retry_delay must not be negative")
+	}
+
+	if c.PageSize < 0 {
+		return fmt.Errorf("page_size must not be negative")
+	}
+
 	return nil
 }
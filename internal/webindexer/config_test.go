@@ -2,6 +2,7 @@ package webindexer
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +48,87 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "order must be one of: asc, desc",
 		},
+		{
+			name: "negative timeout",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				Timeout: -time.Second,
+			},
+			wantErr: true,
+			errMsg:  "timeout must not be negative",
+		},
+		{
+			name: "negative request timeout",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				RequestTimeout: -time.Second,
+			},
+			wantErr: true,
+			errMsg:  "request_timeout must not be negative",
+		},
+		{
+			name: "negative max retries",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				MaxRetries: -1,
+			},
+			wantErr: true,
+			errMsg:  "max_retries must not be negative",
+		},
+		{
+			name: "unknown format",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				Formats: []string{"html", "xml"},
+			},
+			wantErr: true,
+			errMsg:  "formats must be one of: html, json, md",
+		},
+		{
+			name: "sitemap without base url",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				Sitemap: true,
+			},
+			wantErr: true,
+			errMsg:  "base_url is required for sitemaps",
+		},
+		{
+			name: "negative page size",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				PageSize: -1,
+			},
+			wantErr: true,
+			errMsg:  "page_size must not be negative",
+		},
+		{
+			name: "unknown checksum",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				Checksums: []string{"crc32"},
+			},
+			wantErr: true,
+			errMsg:  "checksums must be one or more of: md5, sha1, sha256, sha512",
+		},
+		{
+			name: "checksum files without checksums",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				ChecksumFiles: true,
+			},
+			wantErr: true,
+			errMsg:  "checksums are required for checksum_files",
+		},
+		{
+			name: "verify companions without checksums",
+			config: Config{
+				Source: "some/source/path", Target: "some/target/path", SortBy: "name", Order: "asc",
+				Companions: []string{".sha256"}, VerifyCompanions: true,
+			},
+			wantErr: true,
+			errMsg:  "checksums are required for verify_companions",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,144 @@
+package webindexer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// descriptionsFile describes the items of a directory, one glob pattern
+	// and description per line.
+	descriptionsFile = ".descriptions"
+	// sidecarSuffix is the suffix of the metadata file of an item, such as
+	// foo.tar.gz.meta.yml for foo.tar.gz.
+	sidecarSuffix = ".meta.yml"
+)
+
+// descriptionRule describes the items matching a glob pattern.
+type descriptionRule struct {
+	pattern     string
+	description string
+}
+
+// sidecar is the metadata of an item read from its sidecar file.
+type sidecar struct {
+	Description string `yaml:"description"`
+}
+
+// parseDescriptions parses the descriptions file at file. Each line is a glob
+// pattern followed by whitespace and the description of matching items. Blank
+// lines and lines starting with # are ignored, and malformed lines are logged
+// and skipped.
+func parseDescriptions(file string, content []byte) ([]descriptionRule, error) {
+	var rules []descriptionRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sep := strings.IndexAny(line, " \t")
+		if sep < 0 {
+			log.Warnf("Skipping line %d of %s: missing description for %s", n, file, line)
+			continue
+		}
+		pattern, description := line[:sep], line[sep+1:]
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Warnf("Skipping line %d of %s: invalid pattern %s: %v", n, file, pattern, err)
+			continue
+		}
+
+		rules = append(rules, descriptionRule{pattern: pattern, description: strings.TrimSpace(description)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return rules, fmt.Errorf("unable to read descriptions: %w", err)
+	}
+
+	return rules, nil
+}
+
+// describe sets the description of each item from the descriptions file of
+// the directory and the items' sidecar files, which take precedence. The
+// descriptions file and sidecars are removed from the items. Descriptions
+// that can't be parsed are logged and left out. The read function returns the
+// content of a file in the directory. When listing for stats, the files are
+// removed without being read.
+func (c Config) describe(dir string, items []Item, read func(name string) ([]byte, error)) ([]Item, error) {
+	if !c.Descriptions {
+		return items, nil
+	}
+
+	var rules []descriptionRule
+	described := make([]Item, 0, len(items))
+	sidecars := make(map[string]Item)
+	for _, item := range items {
+		switch {
+		case item.IsDir:
+		case item.Name == descriptionsFile:
+			if c.statsOnly {
+				continue
+			}
+			content, err := read(item.Name)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s in %s: %w", item.Name, dir, err)
+			}
+			rules, err = parseDescriptions(path.Join(dir, item.Name), content)
+			if err != nil {
+				log.Warnf("Unable to parse %s in %s: %v", item.Name, dir, err)
+			}
+
+			continue
+		case strings.HasSuffix(item.Name, sidecarSuffix):
+			sidecars[strings.TrimSuffix(item.Name, sidecarSuffix)] = item
+
+			continue
+		}
+		described = append(described, item)
+	}
+	if c.statsOnly {
+		return described, nil
+	}
+
+	for n := range described {
+		item := &described[n]
+		name := strings.TrimSuffix(item.Name, "/")
+		for _, rule := range rules {
+			if ok, _ := path.Match(rule.pattern, name); ok {
+				item.Description = rule.description
+				break
+			}
+		}
+
+		sc, ok := sidecars[name]
+		if !ok {
+			continue
+		}
+		if sc.SizeBytes > maxNoteSize {
+			log.Warnf("Not reading %s in %s as it is larger than %s", sc.Name, dir, humanizeBytes(maxNoteSize))
+			continue
+		}
+
+		content, err := read(sc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s in %s: %w", sc.Name, dir, err)
+		}
+		var meta sidecar
+		if err := yaml.Unmarshal(content, &meta); err != nil {
+			log.Warnf("Skipping %s in %s: %v", sc.Name, dir, err)
+			continue
+		}
+		if meta.Description != "" {
+			item.Description = strings.TrimSpace(meta.Description)
+		}
+	}
+
+	return described, nil
+}
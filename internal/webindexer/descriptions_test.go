@@ -0,0 +1,129 @@
+package webindexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDescriptions(t *testing.T) {
+	rules, err := parseDescriptions(".descriptions", []byte(`
+# Release artifacts
+*.tar.gz	Source tarball
+*.iso   Installer image
+docs    Documentation
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []descriptionRule{
+		{pattern: "*.tar.gz", description: "Source tarball"},
+		{pattern: "*.iso", description: "Installer image"},
+		{pattern: "docs", description: "Documentation"},
+	}, rules)
+
+	// Malformed lines are skipped
+	rules, err = parseDescriptions(".descriptions", []byte("*.iso\n[ bad pattern\n*.txt Text\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []descriptionRule{{pattern: "*.txt", description: "Text"}}, rules)
+}
+
+func TestDescribe(t *testing.T) {
+	files := map[string]string{
+		".descriptions":       "*.tar.gz Source tarball\n* Anything else\n",
+		"app.tar.gz.meta.yml": "description: Application source\n",
+		"docs.meta.yml":       "description: Documentation\n",
+		"orphan.txt.meta.yml": "description: Nothing\n",
+		"notes.txt.meta.yml":  "other: value\n",
+	}
+	read := func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	}
+	items := []Item{
+		{Name: ".descriptions"},
+		{Name: "app.tar.gz"},
+		{Name: "app.tar.gz.meta.yml"},
+		{Name: "lib.tar.gz"},
+		{Name: "notes.txt"},
+		{Name: "notes.txt.meta.yml"},
+		{Name: "orphan.txt.meta.yml"},
+		{Name: "docs/", IsDir: true},
+		{Name: "docs.meta.yml"},
+	}
+
+	// Descriptions are disabled by default
+	cfg := Config{}
+	described, err := cfg.describe("/dir", items, read)
+	require.NoError(t, err)
+	assert.Equal(t, items, described)
+
+	cfg.Descriptions = true
+	described, err = cfg.describe("/dir", items, read)
+	require.NoError(t, err)
+	assert.Equal(t, []Item{
+		{Name: "app.tar.gz", Description: "Application source"},
+		{Name: "lib.tar.gz", Description: "Source tarball"},
+		{Name: "notes.txt", Description: "Anything else"},
+		{Name: "docs/", IsDir: true, Description: "Documentation"},
+	}, described)
+}
+
+func TestDescribe_Invalid(t *testing.T) {
+	files := map[string]string{
+		".descriptions":       "*.iso\n*.txt Text file\n",
+		"app.tar.gz.meta.yml": "description: [unclosed\n",
+		"notes.txt.meta.yml":  ": not yaml\n  - at all",
+	}
+	read := func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	}
+	items := []Item{
+		{Name: ".descriptions"},
+		{Name: "app.tar.gz"},
+		{Name: "app.tar.gz.meta.yml"},
+		{Name: "disk.iso"},
+		{Name: "notes.txt"},
+		{Name: "notes.txt.meta.yml"},
+	}
+
+	// Bad rules and sidecars are skipped and the rest still apply
+	described, err := Config{Descriptions: true}.describe("/dir", items, read)
+	require.NoError(t, err)
+	assert.Equal(t, []Item{
+		{Name: "app.tar.gz"},
+		{Name: "disk.iso"},
+		{Name: "notes.txt", Description: "Text file"},
+	}, described)
+}
+
+func TestGenerate_Descriptions(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	files := map[string]string{
+		"app.tar.gz":          "archive",
+		"app.tar.gz.meta.yml": "description: The <app> source\n",
+		".descriptions":       "*.txt Release notes\n",
+		"notes.txt":           "notes",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Descriptions = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, "<th>Description</th>")
+		assert.Contains(t, index, `<td class="description">The &lt;app&gt; source</td>`)
+		assert.Contains(t, index, `<td class="description">Release notes</td>`)
+		assert.NotContains(t, index, "meta.yml")
+		assert.NotContains(t, index, ".descriptions")
+	})
+
+	// The descriptions file and sidecars aren't counted as items
+	listing, err := (&LocalBackend{path: sourceDir, cfg: cfg}).List(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Len(t, listing.Items, 2)
+}
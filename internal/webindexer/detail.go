@@ -0,0 +1,214 @@
+package webindexer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// detailDir is the hidden directory detail pages are written to, next to
+	// the files, such as .details/foo.tar.gz.html for foo.tar.gz. Keeping the
+	// pages apart means they can never overwrite a file of the source.
+	detailDir = ".details"
+	// pageSuffix is appended to the name of a file for its detail and viewer
+	// pages.
+	pageSuffix = ".html"
+	// maxPreviewSize is the largest text file previewed on its detail page.
+	maxPreviewSize = 64 << 10
+)
+
+// Detail is the full metadata of a file, shown on its detail page.
+type Detail struct {
+	Item Item
+	// Modified is the modification time of the file in RFC 3339 format.
+	Modified string
+	// ContentType is the media type of the file, guessed from its extension.
+	ContentType string
+	// Preview is the content of a small text file.
+	Preview string
+}
+
+// detailFile returns the path of the detail page of a file relative to its
+// directory.
+func detailFile(name string) string {
+	return path.Join(detailDir, name+pageSuffix)
+}
+
+// pageDirs returns the directories of the enabled pages written for each
+// file, such as detail and viewer pages.
+func (c Config) pageDirs() []string {
+	var dirs []string
+	if c.DetailPages {
+		dirs = append(dirs, detailDir)
+	}
+	if c.Viewer {
+		dirs = append(dirs, viewerDir)
+	}
+
+	return dirs
+}
+
+// isPageDir reports whether an item is the directory of the detail or viewer
+// pages, which isn't listed or indexed when the target is the source.
+func (c Config) isPageDir(item Item) bool {
+	return item.IsDir && contains(c.pageDirs(), strings.TrimSuffix(item.Name, "/"))
+}
+
+// pageURL returns a URL relative to a directory as seen from a page in one
+// of its page directories. Absolute URLs are returned as they are.
+func pageURL(u string) string {
+	if u == "" || strings.HasPrefix(u, "/") {
+		return u
+	}
+	if parsed, err := url.Parse(u); err != nil || parsed.IsAbs() {
+		return u
+	}
+
+	rebased := path.Join("..", u)
+	if strings.HasSuffix(u, "/") {
+		rebased += "/"
+	}
+
+	return rebased
+}
+
+// pageItem returns an item with its URLs rebased for a page in one of the
+// page directories.
+func pageItem(item Item) Item {
+	item.URL = pageURL(item.URL)
+	item.DetailURL = pageURL(item.DetailURL)
+	item.ViewerURL = pageURL(item.ViewerURL)
+	item.ThumbnailURL = pageURL(item.ThumbnailURL)
+	item.Companions = append([]Companion(nil), item.Companions...)
+	for n := range item.Companions {
+		item.Companions[n].URL = pageURL(item.Companions[n].URL)
+	}
+
+	return item
+}
+
+// readPreview returns the content of a small text file for its preview, or
+// an empty string if it is too large, can't be read or isn't text.
+func (i Indexer) readPreview(ctx context.Context, dir string, item Item) string {
+	reader, ok := i.Source.(FileReader)
+	if !ok || item.SizeBytes == 0 || item.SizeBytes > maxPreviewSize {
+		return ""
+	}
+
+	file, err := reader.Open(ctx, dir, item.Name)
+	if err != nil {
+		log.Warnf("Unable to read %s in %s for a preview: %v", item.Name, dir, err)
+		return ""
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxPreviewSize))
+	if err != nil {
+		log.Warnf("Unable to read %s in %s for a preview: %v", item.Name, dir, err)
+		return ""
+	}
+
+	if !isText(content) {
+		return ""
+	}
+
+	return string(content)
+}
+
+// isText reports whether content looks like UTF-8 text.
+func isText(content []byte) bool {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+
+	return bytes.HasPrefix([]byte(http.DetectContentType(content)), []byte("text/"))
+}
+
+// fileData returns the template data of a page about a file in the directory
+// of data, such as its detail page, without the listing. The page is in a
+// page directory, so its relative URLs are one level further up.
+func fileData(data Data, item Item) Data {
+	file := data
+	file.Items = nil
+	file.Pagination = nil
+	file.HasDescriptions = false
+	file.HasChecksums = len(item.Checksums) > 0
+	file.Title = item.Name
+	file.Parent = pageURL(data.Parent)
+	file.RootURL = pageURL(data.RootURL)
+	file.SearchIndex = pageURL(data.SearchIndex)
+
+	// The directory's breadcrumb links back to its listing, followed by one
+	// for the file itself.
+	file.Breadcrumbs = append([]Breadcrumb(nil), data.Breadcrumbs...)
+	for n := range file.Breadcrumbs {
+		file.Breadcrumbs[n].URL = pageURL(file.Breadcrumbs[n].URL)
+	}
+	file.Breadcrumbs[len(file.Breadcrumbs)-1].Current = false
+	file.Breadcrumbs = append(file.Breadcrumbs, Breadcrumb{Name: item.Name, Current: true})
+	file.HasParent = true
+
+	return file
+}
+
+// detailData returns the template data of the detail page of a file in the
+// directory of data.
+func (i Indexer) detailData(ctx context.Context, data Data, item Item) Data {
+	detail := fileData(data, item)
+	d := &Detail{
+		Item:        pageItem(item),
+		ContentType: item.MimeType,
+		Preview:     i.readPreview(ctx, data.Path, item),
+	}
+	if d.ContentType == "" {
+		d.ContentType = contentType(item.Name)
+	}
+	if !item.ModTime.IsZero() {
+		d.Modified = item.ModTime.UTC().Format(time.RFC3339)
+	}
+	detail.Detail = d
+
+	return detail
+}
+
+// writeDetailPages writes a detail page for each file of a listing, if
+// enabled. The pages always use the theme, as a custom template is for
+// listings.
+func (i Indexer) writeDetailPages(ctx context.Context, r *run, data Data) error {
+	if !i.Cfg.DetailPages {
+		return nil
+	}
+
+	for _, item := range data.Items {
+		if item.IsDir {
+			continue
+		}
+
+		o := output{
+			kind:        outputFile,
+			format:      FormatHTML,
+			file:        detailFile(item.Name),
+			contentType: contentTypeHTML,
+		}
+		content, err := i.render(o, i.detailData(ctx, data, item))
+		if err != nil {
+			return fmt.Errorf("unable to render detail page of %s: %w", item.Name, err)
+		}
+
+		if err := i.writeOutput(ctx, r, o, data, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package webindexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHidePages(t *testing.T) {
+	items := []Item{
+		{Name: "app.tar.gz"},
+		{Name: "app.tar.gz.html"},
+		{Name: ".details", IsDir: true},
+		{Name: ".viewer/", IsDir: true},
+		{Name: ".details"},
+	}
+
+	assert.Equal(t, items, visibleItems(Config{}, items))
+	assert.Equal(t, []Item{
+		{Name: "app.tar.gz"},
+		{Name: "app.tar.gz.html"},
+		{Name: ".viewer/", IsDir: true},
+		{Name: ".details"},
+	}, visibleItems(Config{DetailPages: true}, items))
+	assert.Equal(t, []Item{
+		{Name: "app.tar.gz"},
+		{Name: "app.tar.gz.html"},
+		{Name: ".details"},
+	}, visibleItems(Config{DetailPages: true, Viewer: true}, items))
+}
+
+func TestPageURL(t *testing.T) {
+	assert.Equal(t, "../app.tar.gz", pageURL("app.tar.gz"))
+	assert.Equal(t, "../", pageURL("./"))
+	assert.Equal(t, "../../", pageURL("../"))
+	assert.Equal(t, "../.viewer/run.sh.html", pageURL(".viewer/run.sh.html"))
+	assert.Equal(t, "", pageURL(""))
+	assert.Equal(t, "/files/app.tar.gz", pageURL("/files/app.tar.gz"))
+	assert.Equal(t, "https://example.com/app.tar.gz", pageURL("https://example.com/app.tar.gz"))
+}
+
+func TestIsText(t *testing.T) {
+	assert.True(t, isText([]byte("#!/bin/sh\necho hello\n")))
+	assert.True(t, isText([]byte("héllo wörld")))
+	assert.False(t, isText([]byte("hello\x00world")))
+	assert.False(t, isText([]byte{0xff, 0xfe, 0xfd}))
+	assert.False(t, isText([]byte("\x89PNG\r\n\x1a\n")))
+}
+
+func TestDetailData(t *testing.T) {
+	indexer := Indexer{Cfg: Config{BasePath: "/files"}}
+	data := Data{
+		Title:        "Index of /sub",
+		RelativePath: "/sub",
+		Items:        []Item{{Name: "app.tar.gz"}},
+		Breadcrumbs:  indexer.breadcrumbs("/sub"),
+		Pagination:   &Pagination{Current: 1, Total: 2},
+	}
+	item := Item{
+		Name:    "app.tar.gz",
+		URL:     "app.tar.gz",
+		ModTime: time.Date(2024, 5, 2, 8, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+	}
+
+	detail := indexer.detailData(context.Background(), data, item)
+	assert.Equal(t, "app.tar.gz", detail.Title)
+	assert.Nil(t, detail.Items)
+	assert.Nil(t, detail.Pagination)
+	assert.True(t, detail.HasParent)
+	// The page is in the .details directory of the listing
+	assert.Equal(t, []Breadcrumb{
+		{Name: "files", URL: "../../"},
+		{Name: "sub", URL: "../"},
+		{Name: "app.tar.gz", Current: true},
+	}, detail.Breadcrumbs)
+	pageItem := item
+	pageItem.URL = "../app.tar.gz"
+	assert.Equal(t, &Detail{
+		Item:        pageItem,
+		Modified:    "2024-05-02T06:30:00Z",
+		ContentType: "application/gzip",
+	}, detail.Detail)
+
+	// The listing's breadcrumbs aren't changed
+	assert.True(t, data.Breadcrumbs[1].Current)
+}
+
+func TestS3BackendList_ObjectMetadata(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg:    Config{Source: "s3://test-bucket", IndexFile: "index.html"},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{{
+			Key:          aws.String("files/app.tar.gz"),
+			Size:         aws.Int64(5),
+			LastModified: aws.Time(time.Now()),
+			ETag:         aws.String(`"5d41402abc4b2a76b9719d911017c592"`),
+			StorageClass: aws.String("GLACIER_IR"),
+		}},
+	}, nil)
+
+	listing, err := backend.List(context.Background(), "files/")
+	require.NoError(t, err)
+	require.Len(t, listing.Items, 1)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", listing.Items[0].ETag)
+	assert.Equal(t, "GLACIER_IR", listing.Items[0].StorageClass)
+}
+
+func TestGenerate_DetailPages(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh"), []byte("#!/bin/sh\necho <hello>\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "data.bin"), []byte{0, 1, 2}, 0o644))
+	// A file named like the detail page of another one is a file of its own
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh.html"), []byte("<p>real</p>"), 0o644))
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.DetailPages = true
+	cfg.Checksums = []string{"sha256"}
+	cfg.Minify = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<a class="details" href=".details/run.sh.html"`)
+		assert.Contains(t, index, `<a href="run.sh.html">`)
+		assert.Contains(t, index, `<a class="details" href=".details/run.sh.html.html"`)
+		// Detail pages from earlier runs aren't listed
+		assert.NotContains(t, index, `<a href=".details/">`)
+
+		real, err := os.ReadFile(filepath.Join(sourceDir, "run.sh.html"))
+		require.NoError(t, err)
+		assert.Equal(t, "<p>real</p>", string(real))
+
+		detail, err := os.ReadFile(filepath.Join(sourceDir, ".details", "run.sh.html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(detail), `<a href="../run.sh">run.sh</a>`)
+		assert.Contains(t, string(detail), `<a href="../">`)
+		assert.Contains(t, string(detail), "23 bytes")
+		assert.Contains(t, string(detail), `<pre class="preview">#!/bin/sh`+"\n"+`echo &lt;hello&gt;`+"\n</pre>")
+
+		binary, err := os.ReadFile(filepath.Join(sourceDir, ".details", "data.bin.html"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(binary), `class="preview"`)
+	})
+}
+
+func TestGenerate_DetailPagesCustomTemplate(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh"), []byte("echo hello\n"), 0o644))
+	templateFile := filepath.Join(t.TempDir(), "custom.html")
+	require.NoError(t, os.WriteFile(templateFile, []byte(`<p>custom {{ len .Items }}</p>`), 0o644))
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.DetailPages = true
+	cfg.Template = templateFile
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	index, err := os.ReadFile(filepath.Join(targetDir, "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<p>custom 1</p>", string(index))
+
+	// Detail pages are always rendered with the theme
+	detail, err := os.ReadFile(filepath.Join(targetDir, ".details", "run.sh.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(detail), `<a href="../run.sh">run.sh</a>`)
+	assert.NotContains(t, string(detail), "custom")
+}
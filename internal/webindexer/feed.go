@@ -0,0 +1,399 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FeedFormat is the format of the feed of recently modified files.
+type FeedFormat string
+
+const (
+	FeedAtom FeedFormat = "atom"
+	FeedRSS  FeedFormat = "rss"
+	FeedJSON FeedFormat = "json"
+)
+
+// FeedScope controls whether a feed is written for each directory or once
+// for the whole tree.
+type FeedScope string
+
+const (
+	FeedScopeTree      FeedScope = "tree"
+	FeedScopeDirectory FeedScope = "directory"
+)
+
+// FeedFormatValue returns the configured feed format, or an empty string if
+// feeds are disabled.
+func (c Config) FeedFormatValue() FeedFormat {
+	return FeedFormat(strings.ToLower(strings.TrimSpace(c.Feed)))
+}
+
+// FeedScopeValue returns the configured feed scope, defaulting to the whole
+// tree.
+func (c Config) FeedScopeValue() FeedScope {
+	if c.FeedScope == "" {
+		return FeedScopeTree
+	}
+
+	return FeedScope(strings.ToLower(strings.TrimSpace(c.FeedScope)))
+}
+
+// feedOutput returns the feed file to write, if feeds are enabled.
+func (c Config) feedOutput() (output, bool) {
+	o := output{kind: outputFile, file: c.FeedFile}
+	switch c.FeedFormatValue() {
+	case FeedAtom:
+		o.contentType = "application/atom+xml"
+		if o.file == "" {
+			o.file = "atom.xml"
+		}
+	case FeedRSS:
+		o.contentType = "application/rss+xml"
+		if o.file == "" {
+			o.file = "rss.xml"
+		}
+	case FeedJSON:
+		o.contentType = "application/feed+json"
+		if o.file == "" {
+			o.file = "feed.json"
+		}
+	default:
+		return output{}, false
+	}
+
+	return o, true
+}
+
+// validateFeed checks the feed options, if feeds are enabled.
+func (c Config) validateFeed() error {
+	if c.Feed == "" {
+		return nil
+	}
+
+	if _, ok := c.feedOutput(); !ok {
+		return fmt.Errorf("feed must be one of: atom, rss, json")
+	}
+
+	switch c.FeedScopeValue() {
+	case FeedScopeTree, FeedScopeDirectory:
+	default:
+		return fmt.Errorf("feed_scope must be one of: tree, directory")
+	}
+
+	if c.FeedItems <= 0 {
+		return fmt.Errorf("feed_items must be positive")
+	}
+
+	if c.BaseURL == "" {
+		return fmt.Errorf("base_url is required for feeds")
+	}
+
+	return nil
+}
+
+// feedEntry is a file listed in a feed.
+type feedEntry struct {
+	// Title is the path of the file relative to the feed's directory.
+	Title   string
+	URL     string
+	Size    string
+	Updated time.Time
+}
+
+// feedEntries returns the files of a listing as feed entries, titled with
+// their path relative to the feed's directory.
+func feedEntries(data Data, feedDir string) []feedEntry {
+	dir := strings.TrimPrefix(strings.TrimPrefix(data.RelativePath, feedDir), "/")
+
+	entries := make([]feedEntry, 0, len(data.Items))
+	for _, item := range data.Items {
+		if item.IsDir || item.ModTime.IsZero() {
+			continue
+		}
+		entries = append(entries, feedEntry{
+			Title:   path.Join(dir, item.Name),
+			URL:     item.URL,
+			Size:    item.Size,
+			Updated: item.ModTime,
+		})
+	}
+
+	return entries
+}
+
+// mostRecent sorts the entries newest first and keeps at most n of them.
+func mostRecent(entries []feedEntry, n int) []feedEntry {
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].Updated.After(entries[b].Updated)
+	})
+
+	if len(entries) > n {
+		// Copy so that the entries dropped can be garbage collected.
+		entries = append([]feedEntry(nil), entries[:n]...)
+	}
+
+	return entries
+}
+
+// feed is a feed of recently modified files for a directory.
+type feed struct {
+	Title string
+	// Link is the URL of the directory the feed is for.
+	Link string
+	// Self is the URL of the feed itself.
+	Self    string
+	Entries []feedEntry
+}
+
+// newFeed returns the feed for the given directory, with the most recently
+// modified of the given entries.
+func (i Indexer) newFeed(o output, data Data, entries []feedEntry) (feed, error) {
+	link, err := joinURL(i.Cfg.BaseURL, data.RelativePath)
+	if err != nil {
+		return feed{}, fmt.Errorf("unable to build feed link for %s: %w", data.RelativePath, err)
+	}
+
+	self, err := joinURL(i.Cfg.BaseURL, data.RelativePath, o.file)
+	if err != nil {
+		return feed{}, fmt.Errorf("unable to build feed link for %s: %w", data.RelativePath, err)
+	}
+
+	title := data.Title
+	if title == "" {
+		title = "Recently modified files in " + data.RelativePath
+	}
+
+	return feed{
+		Title:   title,
+		Link:    strings.TrimSuffix(link, "/") + "/",
+		Self:    self,
+		Entries: mostRecent(entries, i.Cfg.FeedItems),
+	}, nil
+}
+
+// updated returns the time of the newest entry, so that the feed only
+// changes when the files do.
+func (f feed) updated() time.Time {
+	if len(f.Entries) == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+
+	return f.Entries[0].Updated.UTC()
+}
+
+// render renders the feed in the given format.
+func (f feed) render(format FeedFormat) ([]byte, error) {
+	var content []byte
+	var err error
+	switch format {
+	case FeedAtom:
+		content, err = xml.MarshalIndent(f.atom(), "", "  ")
+	case FeedRSS:
+		content, err = xml.MarshalIndent(f.rss(), "", "  ")
+	case FeedJSON:
+		content, err = json.MarshalIndent(f.jsonFeed(), "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported feed format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s feed: %w", format, err)
+	}
+
+	if format != FeedJSON {
+		content = append([]byte(xml.Header), content...)
+	}
+
+	return append(content, '\n'), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+}
+
+func (f feed) atom() atomFeed {
+	atom := atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.updated().Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.Title},
+		Links:   []atomLink{{Href: f.Self, Rel: "self"}, {Href: f.Link}},
+		Entries: make([]atomEntry, 0, len(f.Entries)),
+	}
+	for _, entry := range f.Entries {
+		atom.Entries = append(atom.Entries, atomEntry{
+			Title:   entry.Title,
+			ID:      entry.URL,
+			Link:    atomLink{Href: entry.URL},
+			Updated: entry.Updated.UTC().Format(time.RFC3339),
+			Summary: entry.summary(),
+		})
+	}
+
+	return atom
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+func (f feed) rss() rssFeed {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Title,
+		LastBuildDate: f.updated().Format(time.RFC1123Z),
+		Items:         make([]rssItem, 0, len(f.Entries)),
+	}
+	for _, entry := range f.Entries {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.URL,
+			GUID:        rssGUID{Value: entry.URL, IsPermaLink: true},
+			PubDate:     entry.Updated.UTC().Format(time.RFC1123Z),
+			Description: entry.summary(),
+		})
+	}
+
+	return rssFeed{Version: "2.0", Channel: channel}
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	Title        string `json:"title"`
+	ContentText  string `json:"content_text"`
+	DateModified string `json:"date_modified"`
+}
+
+func (f feed) jsonFeed() jsonFeed {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Items:       make([]jsonFeedItem, 0, len(f.Entries)),
+	}
+	for _, entry := range f.Entries {
+		jf.Items = append(jf.Items, jsonFeedItem{
+			ID:           entry.URL,
+			URL:          entry.URL,
+			Title:        entry.Title,
+			ContentText:  entry.summary(),
+			DateModified: entry.Updated.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return jf
+}
+
+func (e feedEntry) summary() string {
+	return fmt.Sprintf("%s (%s)", e.Title, e.Size)
+}
+
+// collectFeed writes the feed of a directory, or adds its files to the feed
+// of the whole tree, depending on the feed scope.
+func (i Indexer) collectFeed(ctx context.Context, r *run, data Data) error {
+	o, ok := i.Cfg.feedOutput()
+	if !ok {
+		return nil
+	}
+
+	if i.Cfg.FeedScopeValue() == FeedScopeDirectory {
+		return i.writeFeed(ctx, r, o, data, feedEntries(data, data.RelativePath))
+	}
+
+	// Only the most recent files are kept, so that a large tree doesn't have
+	// to be held in memory.
+	r.feed = append(r.feed, feedEntries(data, r.root)...)
+	if len(r.feed) > 2*i.Cfg.FeedItems {
+		r.feed = mostRecent(r.feed, i.Cfg.FeedItems)
+	}
+
+	return nil
+}
+
+// writeTreeFeed writes the feed of the whole tree to its root directory.
+func (i Indexer) writeTreeFeed(ctx context.Context, r *run, root Data) error {
+	o, ok := i.Cfg.feedOutput()
+	if !ok || i.Cfg.FeedScopeValue() != FeedScopeTree {
+		return nil
+	}
+
+	return i.writeFeed(ctx, r, o, root, r.feed)
+}
+
+// writeFeed renders and writes the feed for a directory.
+func (i Indexer) writeFeed(ctx context.Context, r *run, o output, data Data, entries []feedEntry) error {
+	f, err := i.newFeed(o, data, entries)
+	if err != nil {
+		return err
+	}
+
+	content, err := f.render(i.Cfg.FeedFormatValue())
+	if err != nil {
+		return err
+	}
+
+	return i.writeOutput(ctx, r, o, data, content)
+}
@@ -0,0 +1,180 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFeedOutput(t *testing.T) {
+	_, ok := Config{}.feedOutput()
+	assert.False(t, ok)
+
+	o, ok := Config{Feed: "Atom"}.feedOutput()
+	require.True(t, ok)
+	assert.Equal(t, output{kind: outputFile, file: "atom.xml", contentType: "application/atom+xml"}, o)
+
+	o, ok = Config{Feed: "rss", FeedFile: "releases.rss"}.feedOutput()
+	require.True(t, ok)
+	assert.Equal(t, "releases.rss", o.file)
+}
+
+func TestConfigValidateFeed(t *testing.T) {
+	cfg := Config{Feed: "atom", FeedItems: 10, BaseURL: "https://example.com"}
+	require.NoError(t, cfg.validateFeed())
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		errMsg string
+	}{
+		{"unknown format", func(c *Config) { c.Feed = "rdf" }, "feed must be one of: atom, rss, json"},
+		{"unknown scope", func(c *Config) { c.FeedScope = "bucket" }, "feed_scope must be one of: tree, directory"},
+		{"no items", func(c *Config) { c.FeedItems = 0 }, "feed_items must be positive"},
+		{"no base url", func(c *Config) { c.BaseURL = "" }, "base_url is required for feeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			tt.modify(&cfg)
+			require.EqualError(t, cfg.validateFeed(), tt.errMsg)
+		})
+	}
+}
+
+func TestMostRecent(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	entries := []feedEntry{
+		{Title: "old", Updated: base},
+		{Title: "newest", Updated: base.Add(2 * time.Hour)},
+		{Title: "newer", Updated: base.Add(time.Hour)},
+	}
+
+	recent := mostRecent(entries, 2)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "newest", recent[0].Title)
+	assert.Equal(t, "newer", recent[1].Title)
+}
+
+func TestFeedRender(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := feed{
+		Title: "Releases",
+		Link:  "https://example.com/releases/",
+		Self:  "https://example.com/releases/atom.xml",
+		Entries: []feedEntry{
+			{Title: "v1/app.tar.gz", URL: "https://example.com/releases/v1/app.tar.gz", Size: "1.00 MB", Updated: updated},
+		},
+	}
+
+	content, err := f.render(FeedAtom)
+	require.NoError(t, err)
+	var atom atomFeed
+	require.NoError(t, xml.Unmarshal(content, &atom))
+	assert.Equal(t, "2024-05-01T12:00:00Z", atom.Updated)
+	require.Len(t, atom.Entries, 1)
+	assert.Equal(t, "https://example.com/releases/v1/app.tar.gz", atom.Entries[0].Link.Href)
+
+	content, err = f.render(FeedRSS)
+	require.NoError(t, err)
+	var rss rssFeed
+	require.NoError(t, xml.Unmarshal(content, &rss))
+	assert.Equal(t, "2.0", rss.Version)
+	require.Len(t, rss.Channel.Items, 1)
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 +0000", rss.Channel.Items[0].PubDate)
+
+	content, err = f.render(FeedJSON)
+	require.NoError(t, err)
+	var jf jsonFeed
+	require.NoError(t, json.Unmarshal(content, &jf))
+	assert.Equal(t, "https://example.com/releases/atom.xml", jf.FeedURL)
+	require.Len(t, jf.Items, 1)
+	assert.Equal(t, "v1/app.tar.gz", jf.Items[0].Title)
+}
+
+func TestGenerate_Feed(t *testing.T) {
+	sourceDir := t.TempDir()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]time.Time{
+		"old.txt":       base,
+		"v1/app.tar.gz": base.Add(time.Hour),
+		"v2/app.tar.gz": base.Add(2 * time.Hour),
+	}
+	for name, modTime := range files {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+		require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+	}
+
+	newIndexer := func(scope string) (Indexer, string) {
+		targetDir := t.TempDir()
+		cfg := Config{
+			Source:     sourceDir,
+			Target:     targetDir,
+			Recursive:  true,
+			SortBy:     "name",
+			Order:      "asc",
+			IndexFile:  "index.html",
+			BasePath:   sourceDir,
+			BaseURL:    "https://example.com/files",
+			DateFormat: "2006-01-02",
+			Feed:       "json",
+			FeedItems:  2,
+			FeedScope:  scope,
+		}
+
+		return Indexer{
+			Cfg:    cfg,
+			Source: &LocalBackend{path: sourceDir, cfg: cfg},
+			Target: &LocalBackend{path: targetDir, cfg: cfg},
+		}, targetDir
+	}
+
+	readFeed := func(t *testing.T, file string) jsonFeed {
+		t.Helper()
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var jf jsonFeed
+		require.NoError(t, json.Unmarshal(content, &jf))
+
+		return jf
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		indexer, targetDir := newIndexer("tree")
+		_, err := indexer.Generate(context.Background(), sourceDir)
+		require.NoError(t, err)
+
+		jf := readFeed(t, filepath.Join(targetDir, "feed.json"))
+		assert.Equal(t, "https://example.com/files/feed.json", jf.FeedURL)
+		require.Len(t, jf.Items, 2)
+		assert.Equal(t, "v2/app.tar.gz", jf.Items[0].Title)
+		assert.Equal(t, "https://example.com/files/v2/app.tar.gz", jf.Items[0].URL)
+		assert.Equal(t, "v1/app.tar.gz", jf.Items[1].Title)
+
+		assert.NoFileExists(t, filepath.Join(targetDir, "v1", "feed.json"))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		indexer, targetDir := newIndexer("directory")
+		_, err := indexer.Generate(context.Background(), sourceDir)
+		require.NoError(t, err)
+
+		jf := readFeed(t, filepath.Join(targetDir, "feed.json"))
+		require.Len(t, jf.Items, 1)
+		assert.Equal(t, "old.txt", jf.Items[0].Title)
+
+		jf = readFeed(t, filepath.Join(targetDir, "v1", "feed.json"))
+		require.Len(t, jf.Items, 1)
+		assert.Equal(t, "app.tar.gz", jf.Items[0].Title)
+		assert.Equal(t, "https://example.com/files/v1/", jf.HomePageURL)
+	})
+}
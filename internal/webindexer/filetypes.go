@@ -0,0 +1,289 @@
+package webindexer
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// The kinds of items, which the themes show with different icons.
+const (
+	KindArchive   = "archive"
+	KindAudio     = "audio"
+	KindBinary    = "binary"
+	KindDirectory = "directory"
+	KindDocument  = "document"
+	KindImage     = "image"
+	KindPackage   = "package"
+	KindText      = "text"
+	KindVideo     = "video"
+)
+
+// octetStream is the media type of files whose type isn't known.
+const octetStream = "application/octet-stream"
+
+// sniffSize is the number of bytes read from the start of a file to detect
+// its media type, as used by http.DetectContentType.
+const sniffSize = 512
+
+// FileType overrides the kind and icon of the files with an extension.
+type FileType struct {
+	Kind string `yaml:"kind" mapstructure:"kind"`
+	Icon string `yaml:"icon" mapstructure:"icon"`
+}
+
+// mimeTypes are the media types of common files, which the system's MIME
+// types may not know.
+var mimeTypes = map[string]string{
+	".7z":   "application/x-7z-compressed",
+	".aac":  "audio/aac",
+	".apk":  "application/vnd.android.package-archive",
+	".asc":  "application/pgp-signature",
+	".bz2":  "application/x-bzip2",
+	".deb":  "application/vnd.debian.binary-package",
+	".dmg":  "application/x-apple-diskimage",
+	".flac": "audio/flac",
+	".gz":   "application/gzip",
+	".iso":  "application/x-iso9660-image",
+	".jar":  "application/java-archive",
+	".m4a":  "audio/mp4",
+	".m4v":  "video/mp4",
+	".md":   "text/markdown; charset=utf-8",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".msi":  "application/x-msi",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".ogv":  "video/ogg",
+	".opus": "audio/ogg",
+	".rar":  "application/vnd.rar",
+	".rpm":  "application/x-rpm",
+	".sh":   "application/x-sh",
+	".tar":  "application/x-tar",
+	".tgz":  "application/gzip",
+	".toml": "application/toml",
+	".txt":  "text/plain; charset=utf-8",
+	".wav":  "audio/wav",
+	".weba": "audio/webm",
+	".webm": "video/webm",
+	".xz":   "application/x-xz",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".zip":  "application/zip",
+	".zst":  "application/zstd",
+}
+
+// extensionKinds are the kinds of files known by their extension, such as
+// packages whose media types are generic archives.
+var extensionKinds = map[string]string{
+	".7z":       KindArchive,
+	".appimage": KindPackage,
+	".apk":      KindPackage,
+	".bz2":      KindArchive,
+	".cab":      KindArchive,
+	".crate":    KindPackage,
+	".deb":      KindPackage,
+	".dmg":      KindPackage,
+	".flatpak":  KindPackage,
+	".gem":      KindPackage,
+	".gz":       KindArchive,
+	".ipa":      KindPackage,
+	".iso":      KindArchive,
+	".jar":      KindPackage,
+	".lz":       KindArchive,
+	".lz4":      KindArchive,
+	".lzma":     KindArchive,
+	".msi":      KindPackage,
+	".nupkg":    KindPackage,
+	".pkg":      KindPackage,
+	".rar":      KindArchive,
+	".rpm":      KindPackage,
+	".snap":     KindPackage,
+	".tar":      KindArchive,
+	".tbz2":     KindArchive,
+	".tgz":      KindArchive,
+	".txz":      KindArchive,
+	".vsix":     KindPackage,
+	".whl":      KindPackage,
+	".xpi":      KindPackage,
+	".xz":       KindArchive,
+	".zip":      KindArchive,
+	".zst":      KindArchive,
+}
+
+// mimeKinds are the kinds of media types that don't tell their kind by their
+// top-level type.
+var mimeKinds = map[string]string{
+	"application/gzip":                        KindArchive,
+	"application/java-archive":                KindPackage,
+	"application/javascript":                  KindText,
+	"application/json":                        KindText,
+	"application/msword":                      KindDocument,
+	"application/pdf":                         KindDocument,
+	"application/pgp-signature":               KindText,
+	"application/rtf":                         KindDocument,
+	"application/toml":                        KindText,
+	"application/vnd.android.package-archive": KindPackage,
+	"application/vnd.debian.binary-package":   KindPackage,
+	"application/vnd.rar":                     KindArchive,
+	"application/x-7z-compressed":             KindArchive,
+	"application/x-apple-diskimage":           KindPackage,
+	"application/x-bzip2":                     KindArchive,
+	"application/x-gzip":                      KindArchive,
+	"application/x-msi":                       KindPackage,
+	"application/x-rar-compressed":            KindArchive,
+	"application/x-rpm":                       KindPackage,
+	"application/x-sh":                        KindText,
+	"application/x-tar":                       KindArchive,
+	"application/x-xz":                        KindArchive,
+	"application/xml":                         KindText,
+	"application/yaml":                        KindText,
+	"application/zip":                         KindArchive,
+	"application/zstd":                        KindArchive,
+}
+
+// kinds are the kinds file types can be overridden to.
+var kinds = []string{
+	KindArchive, KindAudio, KindBinary, KindDocument, KindImage, KindPackage, KindText, KindVideo,
+}
+
+// contentType guesses the media type of a file from its extension.
+func contentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := mimeTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+
+	return octetStream
+}
+
+// baseType returns a media type without its parameters, such as text/plain
+// for "text/plain; charset=utf-8".
+func baseType(t string) string {
+	base, _, _ := strings.Cut(t, ";")
+
+	return strings.ToLower(strings.TrimSpace(base))
+}
+
+// knownType reports whether the media type of a file is known by its
+// extension, so that its content needn't be sniffed.
+func knownType(name string) bool {
+	return contentType(name) != octetStream
+}
+
+// sniffs reports whether the content of a file is to be sniffed for its
+// media type.
+func (c Config) sniffs(name string) bool {
+	return c.SniffContent && !c.statsOnly && !knownType(name)
+}
+
+// sniffContentType detects the media type of a file from the start of its
+// content.
+func sniffContentType(r io.Reader) (string, error) {
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return baseType(http.DetectContentType(buf[:n])), nil
+}
+
+// validateFileTypes checks the kinds of the file type overrides.
+func (c Config) validateFileTypes() error {
+	for ext, ft := range c.FileTypes {
+		if ft.Kind != "" && !contains(kinds, ft.Kind) {
+			return fmt.Errorf("file_types kind of %s must be one of: %s", ext, strings.Join(kinds, ", "))
+		}
+	}
+
+	return nil
+}
+
+// sortFileTypes sorts the extensions of the file type overrides, longest
+// first, for fileTypeOverride.
+func (c *Config) sortFileTypes() {
+	c.fileTypeExts = make([]string, 0, len(c.FileTypes))
+	for ext := range c.FileTypes {
+		c.fileTypeExts = append(c.fileTypeExts, ext)
+	}
+	sort.Slice(c.fileTypeExts, func(a, b int) bool { return len(c.fileTypeExts[a]) > len(c.fileTypeExts[b]) })
+}
+
+// fileTypeOverride returns the configured file type of a file, matching the
+// longest extension so that .tar.gz takes precedence over .gz.
+func (c Config) fileTypeOverride(name string) (FileType, bool) {
+	lower := strings.ToLower(name)
+	for _, ext := range c.fileTypeExts {
+		suffix := strings.ToLower(ext)
+		if !strings.HasPrefix(suffix, ".") {
+			suffix = "." + suffix
+		}
+		if strings.HasSuffix(lower, suffix) {
+			return c.FileTypes[ext], true
+		}
+	}
+
+	return FileType{}, false
+}
+
+// fileType sets the media type, kind and icon of an item. A media type
+// already set by the backend, from the content of the file or its S3
+// metadata, takes precedence over its extension.
+func (c Config) fileType(item Item) Item {
+	if item.IsDir {
+		item.Kind = KindDirectory
+		return item
+	}
+
+	if item.MimeType == "" {
+		item.MimeType = baseType(contentType(item.Name))
+	}
+
+	override, _ := c.fileTypeOverride(item.Name)
+	item.Icon = override.Icon
+	item.Kind = override.Kind
+	if item.Kind == "" {
+		item.Kind = kindOf(item.Name, item.MimeType)
+	}
+
+	return item
+}
+
+// kindOf returns the kind of a file from its extension and media type.
+func kindOf(name, mimeType string) string {
+	if kind, ok := extensionKinds[strings.ToLower(path.Ext(name))]; ok {
+		return kind
+	}
+
+	top, sub, _ := strings.Cut(mimeType, "/")
+	switch {
+	case top == KindImage || top == KindAudio || top == KindVideo || top == KindText:
+		return top
+	case mimeKinds[mimeType] != "":
+		return mimeKinds[mimeType]
+	case strings.HasSuffix(sub, "+json") || strings.HasSuffix(sub, "+xml"):
+		return KindText
+	case strings.HasPrefix(sub, "vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(sub, "vnd.oasis.opendocument.") || sub == "epub+zip":
+		return KindDocument
+	}
+
+	// Source code and configuration files without a well-known media type,
+	// such as Makefile or main.go, are text.
+	if lexers.Match(name) != nil {
+		return KindText
+	}
+
+	return KindBinary
+}
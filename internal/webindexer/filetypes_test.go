@@ -0,0 +1,245 @@
+package webindexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "application/gzip", contentType("app.tar.gz"))
+	assert.Equal(t, "text/plain; charset=utf-8", contentType("notes.txt"))
+	assert.Equal(t, "application/octet-stream", contentType("LICENSE"))
+	assert.Equal(t, "text/plain", baseType("Text/Plain; charset=utf-8"))
+	assert.True(t, knownType("photo.JPG"))
+	assert.False(t, knownType("README"))
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"app.tar.gz", KindArchive},
+		{"backup.zip", KindArchive},
+		{"tool_1.0_amd64.deb", KindPackage},
+		{"tool-1.0.x86_64.rpm", KindPackage},
+		{"setup.msi", KindPackage},
+		{"photo.jpg", KindImage},
+		{"logo.svg", KindImage},
+		{"song.mp3", KindAudio},
+		{"clip.webm", KindVideo},
+		{"notes.txt", KindText},
+		{"config.yaml", KindText},
+		{"data.json", KindText},
+		{"main.go", KindText},
+		{"Makefile", KindText},
+		{"manual.pdf", KindDocument},
+		{"report.docx", KindDocument},
+		{"tool.exe", KindBinary},
+		{"LICENSE", KindBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, kindOf(tt.name, baseType(contentType(tt.name))))
+		})
+	}
+}
+
+func TestConfigFileType(t *testing.T) {
+	cfg := Config{FileTypes: map[string]FileType{
+		".gz":     {Icon: "🥡"},
+		"tar.gz":  {Kind: KindPackage, Icon: "📦"},
+		".ISO":    {Kind: KindArchive, Icon: "💿"},
+		".readme": {Kind: KindText},
+	}}
+	cfg.sortFileTypes()
+
+	item := cfg.fileType(Item{Name: "app.tar.gz"})
+	assert.Equal(t, "application/gzip", item.MimeType)
+	assert.Equal(t, KindPackage, item.Kind)
+	assert.Equal(t, "📦", item.Icon)
+
+	item = cfg.fileType(Item{Name: "logs.gz"})
+	assert.Equal(t, KindArchive, item.Kind)
+	assert.Equal(t, "🥡", item.Icon)
+
+	item = cfg.fileType(Item{Name: "disk.iso"})
+	assert.Equal(t, KindArchive, item.Kind)
+	assert.Equal(t, "💿", item.Icon)
+
+	item = cfg.fileType(Item{Name: "INSTALL.readme"})
+	assert.Equal(t, KindText, item.Kind)
+	assert.Empty(t, item.Icon)
+
+	// A media type from the backend takes precedence over the extension
+	item = cfg.fileType(Item{Name: "README", MimeType: "text/plain"})
+	assert.Equal(t, "text/plain", item.MimeType)
+	assert.Equal(t, KindText, item.Kind)
+
+	item = cfg.fileType(Item{Name: "docs", IsDir: true})
+	assert.Equal(t, KindDirectory, item.Kind)
+	assert.Empty(t, item.MimeType)
+}
+
+func TestNew_SortsFileTypes(t *testing.T) {
+	cfg := testConfig(t.TempDir(), t.TempDir())
+	cfg.FileTypes = map[string]FileType{".gz": {Icon: "🥡"}, ".tar.gz": {Kind: KindPackage}}
+
+	indexer, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".tar.gz", ".gz"}, indexer.Cfg.fileTypeExts)
+}
+
+func TestValidateFileTypes(t *testing.T) {
+	assert.NoError(t, Config{}.validateFileTypes())
+	assert.NoError(t, Config{FileTypes: map[string]FileType{".iso": {Kind: KindArchive}}}.validateFileTypes())
+	assert.NoError(t, Config{FileTypes: map[string]FileType{".iso": {Icon: "💿"}}}.validateFileTypes())
+	assert.EqualError(t, Config{FileTypes: map[string]FileType{".iso": {Kind: "disk"}}}.validateFileTypes(),
+		"file_types kind of .iso must be one of: "+strings.Join(kinds, ", "))
+}
+
+func TestSniffContentType(t *testing.T) {
+	contentType, err := sniffContentType(bytes.NewReader(testImage(t, 2, 2, false)))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+
+	contentType, err = sniffContentType(strings.NewReader("hello world\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", contentType)
+
+	contentType, err = sniffContentType(bytes.NewReader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", contentType)
+}
+
+func TestLocalBackendSniffContent(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "screenshot"), testImage(t, 2, 2, false), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "notes.txt"), testImage(t, 2, 2, false), 0o644))
+
+	for _, sniff := range []bool{false, true} {
+		backend := LocalBackend{
+			path: tempDir,
+			cfg:  Config{DateFormat: "2006-01-02", IndexFile: "index.html", SniffContent: sniff},
+		}
+
+		listing, err := backend.List(context.Background(), tempDir)
+		require.NoError(t, err)
+		require.Len(t, listing.Items, 2)
+		mimeTypes := map[string]string{}
+		for _, item := range listing.Items {
+			mimeTypes[item.Name] = item.MimeType
+		}
+		// Files with a known extension are never sniffed
+		assert.Empty(t, mimeTypes["notes.txt"])
+		if sniff {
+			assert.Equal(t, "image/png", mimeTypes["screenshot"])
+		} else {
+			assert.Empty(t, mimeTypes["screenshot"])
+		}
+	}
+}
+
+func TestS3BackendSniffContent(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg:    Config{DateFormat: "2006-01-02", IndexFile: "index.html", SniffContent: true},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("prefix/notes.txt"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+			{Key: aws.String("prefix/screenshot"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+	}, nil).Once()
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Bucket == "test-bucket" && *input.Key == "prefix/screenshot"
+	})).Return(&s3.HeadObjectOutput{ContentType: aws.String("image/png; q=1")}, nil).Once()
+
+	listing, err := backend.List(context.Background(), "prefix")
+	require.NoError(t, err)
+	require.Len(t, listing.Items, 2)
+	assert.Empty(t, listing.Items[0].MimeType)
+	assert.Equal(t, "image/png", listing.Items[1].MimeType)
+
+	mockSvc.AssertExpectations(t)
+}
+
+func TestS3BackendSniffContent_Failure(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg:    Config{DateFormat: "2006-01-02", IndexFile: "index.html", SniffContent: true},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("prefix/screenshot"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+	}, nil).Once()
+	mockSvc.On("HeadObjectWithContext", mock.Anything).
+		Return((*s3.HeadObjectOutput)(nil), awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "")).
+		Once()
+
+	// The item is still listed, typed by its extension
+	listing, err := backend.List(context.Background(), "prefix")
+	require.NoError(t, err)
+	require.Len(t, listing.Items, 1)
+	assert.Empty(t, listing.Items[0].MimeType)
+
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGenerate_FileTypes(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string][]byte{
+		"app.tar.gz": []byte("archive"),
+		"disk.iso":   []byte("disk"),
+		"notes.txt":  []byte("hello"),
+		"screenshot": testImage(t, 2, 2, false),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), content, 0o644))
+	}
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.Formats = []string{"html", "json"}
+	cfg.SniffContent = true
+	cfg.FileTypes = map[string]FileType{".iso": {Kind: KindArchive, Icon: "💿"}}
+	cfg.sortFileTypes()
+	cfg.Minify = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<span class="icon">🗜️</span><a href="app.tar.gz">`)
+		assert.Contains(t, index, `<span class="icon">💿</span><a href="disk.iso">`)
+		assert.Contains(t, index, `<span class="icon">📝</span><a href="notes.txt">`)
+		assert.Contains(t, index, `<span class="icon">🖼️</span><a href="screenshot">`)
+
+		content, err := os.ReadFile(filepath.Join(sourceDir, "index.json"))
+		require.NoError(t, err)
+		var listing jsonListing
+		require.NoError(t, json.Unmarshal(content, &listing))
+		require.Len(t, listing.Items, 4)
+		assert.Equal(t, "application/gzip", listing.Items[0].MimeType)
+		assert.Equal(t, KindArchive, listing.Items[0].Kind)
+		assert.Equal(t, "image/png", listing.Items[3].MimeType)
+		assert.Equal(t, KindImage, listing.Items[3].Kind)
+	})
+}
@@ -0,0 +1,198 @@
+package webindexer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+const (
+	// thumbnailDir is the hidden directory thumbnails are written to, next
+	// to the images.
+	thumbnailDir = ".thumbnails"
+	// maxThumbnailSource is the largest image file a thumbnail is made of.
+	maxThumbnailSource = 64 << 20
+	// maxThumbnailPixels is the largest image, in pixels, a thumbnail is made
+	// of, so that a small file can't decode to an enormous image.
+	maxThumbnailPixels = 50_000_000
+)
+
+// thumbnailExtensions are the extensions of the images thumbnails are made
+// of.
+var thumbnailExtensions = map[string]bool{
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".png":  true,
+	".webp": true,
+}
+
+// validateGallery checks the size of thumbnails.
+func (c Config) validateGallery() error {
+	if c.Gallery && c.ThumbnailSize <= 0 {
+		return fmt.Errorf("thumbnail_size must be positive")
+	}
+
+	return nil
+}
+
+// thumbnailable reports whether a thumbnail is made of an item: an image
+// within the size limit, in gallery mode.
+func (c Config) thumbnailable(item Item) bool {
+	if !c.Gallery || item.IsDir || item.SizeBytes == 0 || item.SizeBytes > maxThumbnailSource {
+		return false
+	}
+
+	return thumbnailExtensions[strings.ToLower(path.Ext(item.Name))]
+}
+
+// thumbnailFile returns the path of the thumbnail of an image relative to its
+// directory, and its content type. Thumbnails of JPEG images are JPEGs, and
+// of anything else PNGs to keep transparency.
+func thumbnailFile(name string) (string, string) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return path.Join(thumbnailDir, name+".jpg"), "image/jpeg"
+	default:
+		return path.Join(thumbnailDir, name+".png"), "image/png"
+	}
+}
+
+// isThumbnailDir reports whether an item is the thumbnail directory, which
+// isn't listed or indexed when the target is the source.
+func (c Config) isThumbnailDir(item Item) bool {
+	return c.Gallery && item.IsDir && strings.TrimSuffix(item.Name, "/") == thumbnailDir
+}
+
+// makeThumbnail decodes an image and scales it down to fit in a square of
+// size pixels, encoded as a JPEG or a PNG. Images already small enough are
+// re-encoded at their own size. For animated GIFs, the first frame is used.
+func makeThumbnail(content []byte, size int, asJPEG bool) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if asJPEG {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readImage returns the content of an image from the source.
+func (i Indexer) readImage(ctx context.Context, dir string, item Item) ([]byte, error) {
+	reader, ok := i.Source.(FileReader)
+	if !ok {
+		return nil, fmt.Errorf("source doesn't support reading files")
+	}
+
+	file, err := reader.Open(ctx, dir, item.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.LimitReader(file, maxThumbnailSource))
+}
+
+// thumbnailCurrent reports whether the target already has a thumbnail of an
+// image written after the image was last modified, if the target can tell.
+// Errors are logged and treated as outdated so the thumbnail is made again.
+func (i Indexer) thumbnailCurrent(ctx context.Context, data Data, item Item, file string) bool {
+	checker, ok := i.target().(ModTimeChecker)
+	if !ok || item.ModTime.IsZero() {
+		return false
+	}
+
+	modTime, exists, err := checker.ModTime(ctx, data.RelativePath, file)
+	if err != nil {
+		log.Warnf("Unable to check the thumbnail of %s in %s: %v", item.Name, data.Path, err)
+		return false
+	}
+
+	return exists && !modTime.Before(item.ModTime)
+}
+
+// writeThumbnails writes a thumbnail of each image of a listing to the
+// thumbnail directory of the target, if the gallery is enabled. Images that
+// can't be read or decoded are listed without a thumbnail. Thumbnails written
+// after their image was last modified are kept as they are.
+func (i Indexer) writeThumbnails(ctx context.Context, r *run, data *Data) error {
+	if !i.Cfg.Gallery {
+		return nil
+	}
+
+	for n := range data.Items {
+		item := &data.Items[n]
+		if item.ThumbnailURL == "" {
+			continue
+		}
+
+		file, contentType := thumbnailFile(item.Name)
+		if i.thumbnailCurrent(ctx, *data, *item, file) {
+			log.Debugf("Thumbnail of %s in %s is up to date", item.Name, data.Path)
+			r.result.FilesUnchanged++
+			continue
+		}
+
+		content, err := i.readImage(ctx, data.Path, *item)
+		if err != nil {
+			log.Warnf("Unable to read %s in %s for a thumbnail: %v", item.Name, data.Path, err)
+			item.ThumbnailURL = ""
+			continue
+		}
+
+		thumbnail, err := makeThumbnail(content, i.Cfg.ThumbnailSize, contentType == "image/jpeg")
+		if err != nil {
+			log.Warnf("Unable to make a thumbnail of %s in %s: %v", item.Name, data.Path, err)
+			item.ThumbnailURL = ""
+			continue
+		}
+
+		o := output{kind: outputFile, file: file, contentType: contentType}
+		if err := i.writeOutput(ctx, r, o, *data, thumbnail); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package webindexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImage returns a PNG or JPEG image of the given size.
+func testImage(t *testing.T, width, height int, asJPEG bool) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if asJPEG {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	} else {
+		require.NoError(t, png.Encode(&buf, img))
+	}
+
+	return buf.Bytes()
+}
+
+func TestValidateGallery(t *testing.T) {
+	assert.NoError(t, Config{}.validateGallery())
+	assert.NoError(t, Config{Gallery: true, ThumbnailSize: 200}.validateGallery())
+	assert.EqualError(t, Config{Gallery: true}.validateGallery(), "thumbnail_size must be positive")
+}
+
+func TestThumbnailable(t *testing.T) {
+	cfg := Config{Gallery: true}
+
+	assert.True(t, cfg.thumbnailable(Item{Name: "shot.png", SizeBytes: 10}))
+	assert.True(t, cfg.thumbnailable(Item{Name: "PHOTO.JPG", SizeBytes: 10}))
+	assert.True(t, cfg.thumbnailable(Item{Name: "anim.gif", SizeBytes: 10}))
+	assert.True(t, cfg.thumbnailable(Item{Name: "icon.webp", SizeBytes: 10}))
+	assert.False(t, cfg.thumbnailable(Item{Name: "logo.svg", SizeBytes: 10}))
+	assert.False(t, cfg.thumbnailable(Item{Name: "empty.png"}))
+	assert.False(t, cfg.thumbnailable(Item{Name: "huge.png", SizeBytes: maxThumbnailSource + 1}))
+	assert.False(t, cfg.thumbnailable(Item{Name: "shots.png", IsDir: true}))
+	assert.False(t, Config{}.thumbnailable(Item{Name: "shot.png", SizeBytes: 10}))
+}
+
+func TestThumbnailFile(t *testing.T) {
+	file, contentType := thumbnailFile("photo.JPEG")
+	assert.Equal(t, ".thumbnails/photo.JPEG.jpg", file)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	file, contentType = thumbnailFile("icon.webp")
+	assert.Equal(t, ".thumbnails/icon.webp.png", file)
+	assert.Equal(t, "image/png", contentType)
+}
+
+func TestHideThumbnails(t *testing.T) {
+	items := []Item{
+		{Name: ".thumbnails", IsDir: true},
+		{Name: ".thumbnails/", IsDir: true},
+		{Name: ".thumbnails"},
+		{Name: "shot.png"},
+	}
+
+	assert.Equal(t, items, visibleItems(Config{}, items))
+	assert.Equal(t, []Item{
+		{Name: ".thumbnails"},
+		{Name: "shot.png"},
+	}, visibleItems(Config{Gallery: true}, items))
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		asJPEG        bool
+		want          image.Point
+	}{
+		{"landscape", 400, 100, false, image.Pt(200, 50)},
+		{"portrait", 100, 400, true, image.Pt(50, 200)},
+		{"small", 40, 30, false, image.Pt(40, 30)},
+		{"thin", 1000, 2, false, image.Pt(200, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnail, err := makeThumbnail(testImage(t, tt.width, tt.height, tt.asJPEG), 200, tt.asJPEG)
+			require.NoError(t, err)
+
+			config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, image.Pt(config.Width, config.Height))
+			if tt.asJPEG {
+				assert.Equal(t, "jpeg", format)
+			} else {
+				assert.Equal(t, "png", format)
+			}
+		})
+	}
+
+	t.Run("not an image", func(t *testing.T) {
+		_, err := makeThumbnail([]byte("not an image"), 200, false)
+		assert.ErrorContains(t, err, "unable to decode image")
+	})
+
+	t.Run("too many pixels", func(t *testing.T) {
+		// A valid PNG header claiming an enormous image, which mustn't be
+		// decoded.
+		content := testImage(t, 1, 1, false)
+		ihdr := content[12:29]
+		binary.BigEndian.PutUint32(ihdr[4:8], 100000)
+		binary.BigEndian.PutUint32(ihdr[8:12], 100000)
+		binary.BigEndian.PutUint32(content[29:33], crc32.ChecksumIEEE(ihdr))
+
+		_, err := makeThumbnail(content, 200, false)
+		assert.EqualError(t, err, "image of 100000x100000 pixels is too large")
+	})
+}
+
+func TestGenerate_Gallery(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "shot.png"), testImage(t, 640, 480, false), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "broken.jpg"), []byte("not a JPEG"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(sourceDir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "sub", "photo.jpg"), testImage(t, 300, 600, true), 0o644))
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.Recursive = true
+	cfg.Gallery = true
+	cfg.ThumbnailSize = 128
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		thumbnail, err := os.ReadFile(filepath.Join(sourceDir, ".thumbnails", "shot.png.png"))
+		require.NoError(t, err)
+		config, err := png.DecodeConfig(bytes.NewReader(thumbnail))
+		require.NoError(t, err)
+		assert.Equal(t, 128, config.Width)
+		assert.Equal(t, 96, config.Height)
+
+		_, err = os.Stat(filepath.Join(sourceDir, "sub", ".thumbnails", "photo.jpg.jpg"))
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(sourceDir, ".thumbnails", "broken.jpg.jpg"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		assert.Contains(t, index, `<ul class="gallery">`)
+		assert.Contains(t, index, `<img src=".thumbnails/shot.png.png" alt="shot.png" loading="lazy">`)
+		// Images without a thumbnail get an icon
+		assert.NotContains(t, index, `alt="broken.jpg"`)
+		assert.Contains(t, index, `title="broken.jpg"`)
+		// The thumbnail directory from the earlier run isn't listed
+		assert.NotContains(t, index, `title=".thumbnails"`)
+		_, err = os.Stat(filepath.Join(sourceDir, ".thumbnails", "index.html"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestGenerate_GalleryUpToDate(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	imagePath := filepath.Join(sourceDir, "shot.png")
+	require.NoError(t, os.WriteFile(imagePath, testImage(t, 640, 480, false), 0o644))
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Gallery = true
+	cfg.ThumbnailSize = 128
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FilesWritten)
+
+	// A thumbnail written after its image was modified is kept as it is
+	thumbnailPath := filepath.Join(targetDir, ".thumbnails", "shot.png.png")
+	require.NoError(t, os.WriteFile(thumbnailPath, []byte("kept"), 0o644))
+
+	result, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.FilesWritten)
+	assert.Equal(t, 1, result.FilesUnchanged)
+	thumbnail, err := os.ReadFile(thumbnailPath)
+	require.NoError(t, err)
+	assert.Equal(t, "kept", string(thumbnail))
+	index, err := os.ReadFile(filepath.Join(targetDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `src=".thumbnails/shot.png.png"`)
+
+	// A thumbnail older than its image is made again
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(imagePath, modTime, modTime))
+
+	result, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FilesWritten)
+	thumbnail, err = os.ReadFile(thumbnailPath)
+	require.NoError(t, err)
+	_, err = png.DecodeConfig(bytes.NewReader(thumbnail))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,41 @@
+package webindexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layout is how the built-in themes show the items of a listing. Visitors
+// can switch between the layouts, which all render the same Data, and their
+// choice is kept in the browser's localStorage.
+type Layout string
+
+const (
+	// LayoutDetailed is a table of the items with their size, date and
+	// description.
+	LayoutDetailed Layout = "detailed"
+	// LayoutCompact is a dense list of the item names.
+	LayoutCompact Layout = "compact"
+	// LayoutGrid is a grid of large icons.
+	LayoutGrid Layout = "grid"
+)
+
+// LayoutValue returns the configured default layout, defaulting to the
+// detailed table.
+func (c Config) LayoutValue() Layout {
+	if c.Layout == "" {
+		return LayoutDetailed
+	}
+
+	return Layout(strings.ToLower(strings.TrimSpace(c.Layout)))
+}
+
+// validateLayout checks the default layout.
+func (c Config) validateLayout() error {
+	switch c.LayoutValue() {
+	case LayoutDetailed, LayoutCompact, LayoutGrid:
+		return nil
+	default:
+		return fmt.Errorf("layout must be one of: detailed, compact, grid")
+	}
+}
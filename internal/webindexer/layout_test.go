@@ -0,0 +1,39 @@
+package webindexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutValue(t *testing.T) {
+	assert.Equal(t, LayoutDetailed, Config{}.LayoutValue())
+	assert.Equal(t, LayoutCompact, Config{Layout: "compact"}.LayoutValue())
+	assert.Equal(t, LayoutGrid, Config{Layout: " Grid "}.LayoutValue())
+}
+
+func TestValidateLayout(t *testing.T) {
+	assert.NoError(t, Config{}.validateLayout())
+	assert.NoError(t, Config{Layout: "grid"}.validateLayout())
+	assert.EqualError(t, Config{Layout: "list"}.validateLayout(), "layout must be one of: detailed, compact, grid")
+}
+
+func TestGenerate_Layout(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("hello"), 0o644))
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.Layout = "compact"
+	cfg.Minify = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<table class="listing layout-compact" id="listing">`)
+		assert.Contains(t, index, `data-layout="compact" title="Compact" aria-pressed="true"`)
+		assert.Contains(t, index, `data-layout="grid" title="Grid" aria-pressed="false"`)
+		assert.Contains(t, index, `localStorage.setItem("web-indexer-layout", layout)`)
+		assert.Contains(t, index, `<a href="notes.txt">notes.txt</a>`)
+	})
+}
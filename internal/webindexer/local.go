@@ -1,99 +1,269 @@
 package webindexer
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 )
 
 type LocalBackend struct {
-	path string
-	cfg  Config
+	path      string
+	cfg       Config
+	checksums *checksumCache
 }
 
-var _ FileSource = &LocalBackend{}
+var (
+	_ FileSource      = &LocalBackend{}
+	_ FileSourceV2    = &LocalBackend{}
+	_ ItemIterator    = &LocalBackend{}
+	_ FileWriter      = &LocalBackend{}
+	_ ContentComparer = &LocalBackend{}
+	_ FileReader      = &LocalBackend{}
+	_ FileRemover     = &LocalBackend{}
+	_ ModTimeChecker  = &LocalBackend{}
+)
+
+// localReadBatch is the number of directory entries read at a time when
+// iterating over a local directory.
+const localReadBatch = 256
 
 func (l *LocalBackend) Read(path string) ([]Item, bool, error) {
-	var items []Item
-	log.Debugf("Listing files in %s", path)
-	files, err := os.ReadDir(path)
+	listing, err := l.List(context.Background(), path)
 	if err != nil {
-		return nil, false, fmt.Errorf("unable to read source path %s: %w", path, err)
+		return nil, false, err
 	}
 
-	// First check for noindex files before processing anything else
-	for _, file := range files {
-		if !file.IsDir() {
-			// Check for noindex files (skip directory entirely)
-			if len(l.cfg.NoIndexFiles) > 0 && contains(l.cfg.NoIndexFiles, file.Name()) {
-				log.Infof("Skipping %s (found noindex file %s)", path, file.Name())
-				return nil, true, nil
-			}
+	items, hasNoIndex := legacyItems(listing)
 
-			// Check for skipindex files (skip indexing but include in parent)
-			if len(l.cfg.SkipIndexFiles) > 0 && contains(l.cfg.SkipIndexFiles, file.Name()) {
-				log.Infof("Skipping indexing of %s (found skipindex file %s), will include in parent directory", path, file.Name())
-				// Return empty items but mark as not having noindex file
-				// This will prevent indexing this directory but still include it in the parent
-				return []Item{}, false, nil
-			}
-		}
+	return items, hasNoIndex, nil
+}
+
+// List reads the directory at path, honouring noindex and skipindex files.
+func (l *LocalBackend) List(ctx context.Context, path string) (Listing, error) {
+	var noIndexDirs []string
+	listing, err := collectListing(l.listItems(ctx, path, func(name string) {
+		noIndexDirs = append(noIndexDirs, name)
+	}))
+	if err != nil {
+		return Listing{}, err
 	}
+	listing.NoIndexDirs = noIndexDirs
+	listing.Metadata = map[string]string{"path": path}
 
-	// Process all other files
-	for _, file := range files {
-		if shouldSkip(file.Name(), l.cfg.IndexFile, l.cfg.Skips) {
-			continue
+	if listing.Marker == MarkerNone {
+		listing.Notes, err = l.cfg.readNotes(path, func(name string) ([]byte, bool, error) {
+			return readLocalNote(filepath.Join(path, name))
+		})
+		if err != nil {
+			return Listing{}, err
 		}
+	}
+
+	return listing, nil
+}
+
+// readLocalNote reads a header or readme file, returning false if it doesn't
+// exist or is too large to render.
+func readLocalNote(path string) ([]byte, bool, error) {
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if stat.IsDir() {
+		return nil, false, nil
+	}
+	if stat.Size() > maxNoteSize {
+		log.Warnf("Not rendering %s as it is larger than %s", path, humanizeBytes(maxNoteSize))
+		return nil, false, nil
+	}
+
+	content, err := os.ReadFile(path) // #nosec
+	if err != nil {
+		return nil, false, err
+	}
+
+	return content, true, nil
+}
+
+// Iterate yields the items of the directory at path as List lists them. The
+// entries are read in batches, so unless descriptions or companions are
+// enabled, the whole directory isn't read into memory first.
+func (l *LocalBackend) Iterate(ctx context.Context, path string) iter.Seq2[Item, error] {
+	return l.listItems(ctx, path, func(string) {})
+}
+
+// listItems yields the items of the listing of the directory at path.
+func (l *LocalBackend) listItems(ctx context.Context, path string, omit func(name string)) iter.Seq2[Item, error] {
+	return l.cfg.listItems(path, l.iterate(ctx, path, omit), func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(path, name)) // #nosec
+	})
+}
 
-		fullPath := filepath.Join(path, file.Name())
-		stat, err := os.Stat(fullPath)
+// iterate yields the entries of the directory at path, calling omit with the
+// name of each subdirectory that is left out because it contains a noindex
+// file.
+func (l *LocalBackend) iterate(ctx context.Context, path string, omit func(name string)) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		log.Debugf("Listing files in %s", path)
+		dir, err := os.Open(path) // #nosec
 		if err != nil {
-			return nil, false, fmt.Errorf("unable to stat file %s: %w", file.Name(), err)
+			yield(Item{}, fmt.Errorf("unable to read source path %s: %w", path, err))
+			return
 		}
+		defer dir.Close()
 
-		// If it's a directory, check if it contains a noindex or skipindex file before adding it
-		if stat.IsDir() {
-			subFiles, err := os.ReadDir(fullPath)
-			if err != nil {
-				return nil, false, fmt.Errorf("unable to read directory %s: %w", fullPath, err)
+		// Check for marker files before processing anything else
+		switch marker, file := l.marker(path); marker {
+		case MarkerNoIndex:
+			log.Infof("Skipping %s (found noindex file %s)", path, file)
+			yield(Item{}, &MarkerError{Path: path, File: file, Marker: marker})
+			return
+		case MarkerSkipIndex:
+			log.Infof("Skipping indexing of %s (found skipindex file %s), will include in parent directory",
+				path, file)
+			yield(Item{}, &MarkerError{Path: path, File: file, Marker: marker})
+			return
+		case MarkerNone:
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(Item{}, err)
+				return
 			}
 
-			// Skip this directory if it contains a noindex file
-			skipDir := false
-			for _, subFile := range subFiles {
-				if !subFile.IsDir() && len(l.cfg.NoIndexFiles) > 0 && contains(l.cfg.NoIndexFiles, subFile.Name()) {
-					log.Infof("Skipping %s (found noindex file %s)", fullPath, subFile.Name())
-					skipDir = true
-					break
+			entries, readErr := dir.ReadDir(localReadBatch)
+			for _, entry := range entries {
+				item, ok, err := l.item(path, entry, omit)
+				if err != nil {
+					yield(Item{}, err)
+					return
+				}
+				if ok && !yield(item, nil) {
+					return
 				}
 			}
-			if skipDir {
-				continue
+
+			if errors.Is(readErr, io.EOF) {
+				return
+			}
+			if readErr != nil {
+				yield(Item{}, fmt.Errorf("unable to read source path %s: %w", path, readErr))
+				return
+			}
+		}
+	}
+}
+
+// item builds the listing item for a directory entry. It returns false if the
+// entry should not be listed.
+func (l *LocalBackend) item(path string, entry fs.DirEntry, omit func(name string)) (Item, bool, error) {
+	if l.cfg.shouldSkip(entry.Name()) {
+		return Item{}, false, nil
+	}
+
+	fullPath := filepath.Join(path, entry.Name())
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return Item{}, false, fmt.Errorf("unable to stat file %s: %w", entry.Name(), err)
+	}
+
+	// Skip directories that contain a noindex file
+	if stat.IsDir() {
+		if marker, file := l.marker(fullPath); marker == MarkerNoIndex {
+			log.Infof("Skipping %s (found noindex file %s)", fullPath, file)
+			omit(entry.Name())
+			return Item{}, false, nil
+		}
+	}
+
+	item := Item{
+		Name:         entry.Name(),
+		Size:         humanizeBytes(stat.Size()),
+		LastModified: stat.ModTime().Format(l.cfg.DateFormat),
+		IsDir:        stat.IsDir(),
+		SizeBytes:    stat.Size(),
+		ModTime:      stat.ModTime(),
+	}
+
+	if !item.IsDir {
+		item.Checksums = l.cfg.checksums(l.checksums, fullPath, stat.Size(), stat.ModTime(),
+			func() (io.ReadCloser, error) {
+				return os.Open(fullPath) // #nosec
+			})
+
+		if l.cfg.sniffs(item.Name) {
+			item.MimeType, err = sniffFile(fullPath)
+			if err != nil {
+				log.Warnf("Unable to detect the type of %s: %v", fullPath, err)
 			}
 		}
+	}
+
+	return item, true, nil
+}
 
-		size := humanizeBytes(stat.Size())
-		modified := stat.ModTime().Format(l.cfg.DateFormat)
+// sniffFile detects the media type of a local file from its content.
+func sniffFile(path string) (string, error) {
+	file, err := os.Open(path) // #nosec
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
 
-		itemName := file.Name()
-		item := Item{
-			Name:         itemName,
-			Size:         size,
-			LastModified: modified,
-			IsDir:        stat.IsDir(),
+	return sniffContentType(file)
+}
+
+// marker checks the directory at path for noindex and skipindex files. A
+// noindex file takes precedence over a skipindex file.
+func (l *LocalBackend) marker(path string) (Marker, string) {
+	for _, name := range l.cfg.NoIndexFiles {
+		if isLocalFile(filepath.Join(path, name)) {
+			return MarkerNoIndex, name
 		}
+	}
 
-		items = append(items, item)
+	for _, name := range l.cfg.SkipIndexFiles {
+		if isLocalFile(filepath.Join(path, name)) {
+			return MarkerSkipIndex, name
+		}
 	}
 
-	return items, false, nil
+	return MarkerNone, ""
+}
+
+// Open opens the file name in the directory at path for reading.
+func (l *LocalBackend) Open(_ context.Context, path, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(path, name)) // #nosec
+}
+
+func isLocalFile(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
 }
 
 func (l *LocalBackend) EnsureDirExists(relativePath string) error {
+	return l.EnsureDirExistsContext(context.Background(), relativePath)
+}
+
+func (l *LocalBackend) EnsureDirExistsContext(ctx context.Context, relativePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	localPath := filepath.Join(l.cfg.Target, relativePath)
 	if err := os.MkdirAll(localPath, 0o750); err != nil {
 		return fmt.Errorf("failed to ensure directory exists %s: %w", localPath, err)
@@ -103,49 +273,33 @@ func (l *LocalBackend) EnsureDirExists(relativePath string) error {
 }
 
 func (l *LocalBackend) Write(data Data, content string) error {
-	prefix := data.RelativePath
-	prefix = strings.TrimPrefix(prefix, l.cfg.BasePath)
-
-	// Remove any leading slashes to avoid creating unnecessary subdirectories
-	prefix = strings.TrimPrefix(prefix, "/")
-
-	// For the root directory, don't create an additional subdirectory
-	if prefix == "" || prefix == "/" {
-		localPath := l.cfg.Target
-		if err := os.MkdirAll(localPath, 0o750); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", localPath, err)
-		}
-
-		filePath := filepath.Join(localPath, l.cfg.IndexFile)
-		file, err := os.Create(filePath) // #nosec
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	return l.WriteContext(context.Background(), data, content)
+}
 
-		_, err = file.WriteString(content)
-		if err != nil {
-			return err
-		}
+func (l *LocalBackend) WriteContext(ctx context.Context, data Data, content string) error {
+	return l.WriteFile(ctx, data.RelativePath, l.cfg.IndexFile, []byte(content), contentTypeHTML)
+}
 
-		log.Infof("Generated %s", filePath)
-		return nil
+// WriteFile writes a file to the target directory for dir, creating any
+// missing directories.
+func (l *LocalBackend) WriteFile(ctx context.Context, dir, name string, content []byte, _ string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// For subdirectories, create the necessary structure
-	localPath := filepath.Join(l.cfg.Target, prefix)
+	filePath := filepath.Join(l.targetDir(dir), filepath.FromSlash(name))
+	localPath := filepath.Dir(filePath)
 	if err := os.MkdirAll(localPath, 0o750); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", localPath, err)
 	}
 
-	filePath := filepath.Join(localPath, l.cfg.IndexFile)
 	file, err := os.Create(filePath) // #nosec
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(content)
+	_, err = file.Write(content)
 	if err != nil {
 		return err
 	}
@@ -153,3 +307,62 @@ func (l *LocalBackend) Write(data Data, content string) error {
 	log.Infof("Generated %s", filePath)
 	return nil
 }
+
+// Unchanged reports whether the file already exists in the target directory
+// for dir with the given content.
+func (l *LocalBackend) Unchanged(_ context.Context, dir, name string, content []byte) (bool, error) {
+	existing, err := os.ReadFile(filepath.Join(l.targetDir(dir), filepath.FromSlash(name))) // #nosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(existing, content), nil
+}
+
+// ModTime returns the modification time of a file in the target directory
+// for dir.
+func (l *LocalBackend) ModTime(_ context.Context, dir, name string) (time.Time, bool, error) {
+	stat, err := os.Stat(filepath.Join(l.targetDir(dir), filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return stat.ModTime(), true, nil
+}
+
+// RemoveFile deletes a file from the target directory for dir, if it exists.
+func (l *LocalBackend) RemoveFile(ctx context.Context, dir, name string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	filePath := filepath.Join(l.targetDir(dir), filepath.FromSlash(name))
+	err := os.Remove(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Removed %s", filePath)
+
+	return true, nil
+}
+
+// targetDir returns the local directory that files for the relative
+// directory dir are written to.
+func (l *LocalBackend) targetDir(dir string) string {
+	prefix := strings.TrimPrefix(dir, l.cfg.BasePath)
+
+	// Remove any leading slashes to avoid creating unnecessary subdirectories.
+	// The root directory is written directly to the target.
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	return filepath.Join(l.cfg.Target, prefix)
+}
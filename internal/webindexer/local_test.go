@@ -1,6 +1,8 @@
 package webindexer
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -168,3 +170,146 @@ func TestLocalBackendWrite(t *testing.T) {
 
 	assert.Equal(t, strings.TrimSpace(content), strings.TrimSpace(string(readContent)), "File content does not match")
 }
+
+func TestLocalBackendWriteFile(t *testing.T) {
+	targetDir := t.TempDir()
+	localBackend := LocalBackend{
+		cfg: Config{
+			BasePath: "/base",
+			Target:   targetDir,
+		},
+	}
+
+	content := []byte(`{"items": []}`)
+	err := localBackend.WriteFile(context.Background(), "/base/subdir/", "nested/index.json", content, "application/json")
+	require.NoError(t, err)
+
+	readContent, err := os.ReadFile(filepath.Join(targetDir, "subdir", "nested", "index.json"))
+	require.NoError(t, err)
+	assert.Equal(t, content, readContent)
+
+	unchanged, err := localBackend.Unchanged(context.Background(), "/base/subdir/", "nested/index.json", content)
+	require.NoError(t, err)
+	assert.True(t, unchanged)
+
+	unchanged, err = localBackend.Unchanged(context.Background(), "/base/subdir/", "missing.json", content)
+	require.NoError(t, err)
+	assert.False(t, unchanged)
+}
+
+func TestLocalBackendListWithSkipIndex(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"file1.txt":  "test content",
+		".skipindex": "",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644)
+		require.NoError(t, err)
+	}
+
+	localBackend := LocalBackend{
+		path: tempDir,
+		cfg: Config{
+			DateFormat:     "2006-01-02",
+			IndexFile:      "index.html",
+			NoIndexFiles:   []string{".noindex"},
+			SkipIndexFiles: []string{".skipindex"},
+		},
+	}
+
+	listing, err := localBackend.List(context.Background(), tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, MarkerSkipIndex, listing.Marker)
+	assert.Equal(t, ".skipindex", listing.MarkerFile)
+	assert.Empty(t, listing.Items)
+
+	// The legacy interface reports skipindex as an empty, non-nil listing
+	items, hasNoIndex, err := localBackend.Read(tempDir)
+	require.NoError(t, err)
+	assert.False(t, hasNoIndex)
+	assert.NotNil(t, items)
+	assert.Empty(t, items)
+}
+
+func TestLocalBackendIterate_MatchesList(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"app.tar.gz":          "app",
+		"app.tar.gz.sha256":   "sum",
+		"app.tar.gz.meta.yml": "description: The app\n",
+		".descriptions":       "*.txt Notes\n",
+		"notes.txt":           "notes",
+		".details/x.html":     "page",
+		".thumbnails/x.png":   "thumbnail",
+		"sub/file.txt":        "file",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0o644))
+	}
+
+	for _, cfg := range []Config{
+		{IndexFile: "index.html", DetailPages: true, Gallery: true},
+		{IndexFile: "index.html", DetailPages: true, Gallery: true, Descriptions: true, Companions: []string{".sha256"}},
+	} {
+		localBackend := LocalBackend{path: tempDir, cfg: cfg}
+
+		listing, err := localBackend.List(context.Background(), tempDir)
+		require.NoError(t, err)
+
+		var items []Item
+		for item, err := range localBackend.Iterate(context.Background(), tempDir) {
+			require.NoError(t, err)
+			items = append(items, item)
+		}
+
+		assert.ElementsMatch(t, listing.Items, items)
+		hidden := []string{".details", ".thumbnails"}
+		if cfg.Descriptions {
+			hidden = append(hidden, ".descriptions", "app.tar.gz.meta.yml", "app.tar.gz.sha256")
+		}
+		for _, item := range items {
+			assert.NotContains(t, hidden, item.Name)
+		}
+	}
+}
+
+func TestLocalBackendIterate(t *testing.T) {
+	tempDir := t.TempDir()
+	for i := range localReadBatch + 10 {
+		name := filepath.Join(tempDir, fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte("x"), 0o644))
+	}
+
+	localBackend := LocalBackend{
+		path: tempDir,
+		cfg:  Config{IndexFile: "index.html"},
+	}
+
+	// All entries are yielded across multiple batches
+	count := 0
+	for _, err := range localBackend.Iterate(context.Background(), tempDir) {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, localReadBatch+10, count)
+
+	// Iteration stops as soon as the consumer does
+	count = 0
+	for range localBackend.Iterate(context.Background(), tempDir) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+
+	// A cancelled context ends the iteration with the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := localBackend.List(ctx, tempDir)
+	require.ErrorIs(t, err, context.Canceled)
+}
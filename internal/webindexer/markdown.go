@@ -0,0 +1,58 @@
+package webindexer
+
+import (
+	"bytes"
+	_ "embed"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/formats/markdown.md.tmpl
+var markdownTemplate string
+
+// markdownEscaper escapes characters that would otherwise be read as
+// Markdown syntax or end a table cell.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;",
+)
+
+// markdownLinkEscaper escapes parentheses, which url.PathEscape leaves as-is
+// but would end a Markdown link.
+var markdownLinkEscaper = strings.NewReplacer("(", "%28", ")", "%29")
+
+// markdownListing is the data for the Markdown template. Directories link to
+// their own listing file so that the listings can be browsed on sites like
+// GitHub that render Markdown.
+type markdownListing struct {
+	Data
+	IndexFile string
+}
+
+// renderMarkdown renders the listing as a Markdown table with relative links.
+func renderMarkdown(o output, data Data) ([]byte, error) {
+	tmpl, err := template.New("markdown").Funcs(template.FuncMap{
+		"escape": markdownEscaper.Replace,
+		"link":   markdownLink,
+	}).Parse(markdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := new(bytes.Buffer)
+	if err := tmpl.Execute(generated, markdownListing{Data: data, IndexFile: o.file}); err != nil {
+		return nil, err
+	}
+
+	return generated.Bytes(), nil
+}
+
+// markdownLink joins and escapes path segments into a relative link.
+func markdownLink(segments ...string) string {
+	escaped := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		escaped = append(escaped, markdownLinkEscaper.Replace(url.PathEscape(strings.Trim(segment, "/"))))
+	}
+
+	return strings.Join(escaped, "/")
+}
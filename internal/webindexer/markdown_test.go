@@ -0,0 +1,94 @@
+package webindexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	data := Data{
+		Title:     "Index of /files",
+		HasParent: true,
+		Items: []Item{
+			{Name: "docs/", IsDir: true, Indexed: true},
+			{Name: "empty/", IsDir: true},
+			{Name: "my file (1)_v2.txt", Size: "5.00 B", LastModified: "2024-05-01"},
+		},
+	}
+
+	content, err := renderMarkdown(output{format: FormatMarkdown, file: "INDEX.md"}, data)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# Index of /files
+
+| Name | Size | Last Modified |
+| --- | --- | --- |
+| ⬆️ [Parent directory](../INDEX.md) | - | - |
+| 📁 [docs/](docs/INDEX.md) | - | - |
+| 📁 [empty/](empty/) | - | - |
+| 📄 [my file (1)\_v2.txt](my%20file%20%281%29_v2.txt) | 5.00 B | 2024-05-01 |
+`, string(content))
+}
+
+func TestRenderMarkdown_NoTitle(t *testing.T) {
+	content, err := renderMarkdown(output{format: FormatMarkdown, file: "README.md"}, Data{
+		Items: []Item{{Name: "a|b", Size: "1.00 B", LastModified: "2024-05-01"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `| Name | Size | Last Modified |
+| --- | --- | --- |
+| 📄 [a\|b](a%7Cb) | 1.00 B | 2024-05-01 |
+`, string(content))
+}
+
+func TestMarkdownLink(t *testing.T) {
+	assert.Equal(t, "docs/INDEX.md", markdownLink("docs/", "INDEX.md"))
+	assert.Equal(t, "a%20b", markdownLink("a b"))
+}
+
+func TestGenerate_MarkdownDirectoryLinks(t *testing.T) {
+	for _, recursive := range []bool{true, false} {
+		sourceDir := t.TempDir()
+		targetDir := t.TempDir()
+		for _, name := range []string{"sub/a.txt", "skipped/.skipindex", "skipped/b.txt"} {
+			fullPath := filepath.Join(sourceDir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+			require.NoError(t, os.WriteFile(fullPath, []byte("hello"), 0o644))
+		}
+		require.NoError(t, os.Mkdir(filepath.Join(sourceDir, "empty"), 0o755))
+
+		cfg := testConfig(sourceDir, targetDir)
+		cfg.Recursive = recursive
+		cfg.Formats = []string{"md"}
+		cfg.SkipIndexFiles = []string{".skipindex"}
+		indexer := Indexer{
+			Cfg:    cfg,
+			Source: &LocalBackend{path: sourceDir, cfg: cfg},
+			Target: &LocalBackend{path: targetDir, cfg: cfg},
+		}
+
+		_, err := indexer.Generate(context.Background(), sourceDir)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(targetDir, "INDEX.md"))
+		require.NoError(t, err)
+
+		// Only directories that got a listing link to it
+		if recursive {
+			assert.Contains(t, string(content), "(sub/INDEX.md)")
+			assert.FileExists(t, filepath.Join(targetDir, "sub", "INDEX.md"))
+		} else {
+			assert.Contains(t, string(content), "(sub/)")
+		}
+		assert.Contains(t, string(content), "(empty/)")
+		assert.Contains(t, string(content), "(skipped/)")
+		assert.NoFileExists(t, filepath.Join(targetDir, "empty", "INDEX.md"))
+		assert.NoFileExists(t, filepath.Join(targetDir, "skipped", "INDEX.md"))
+	}
+}
@@ -0,0 +1,354 @@
+package webindexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// MediaAudio and MediaVideo are the kinds of media items.
+	MediaAudio = "audio"
+	MediaVideo = "video"
+
+	// maxMediaProbe is the most read from a media file that can't be seeked,
+	// such as an S3 object, to find its metadata.
+	maxMediaProbe = 8 << 20
+	// maxMediaHeader is the largest metadata box read into memory, such as
+	// the moov box of an MP4 file.
+	maxMediaHeader = 16 << 20
+)
+
+// errMediaMetadata is returned when a media file has no metadata that can be
+// read.
+var errMediaMetadata = errors.New("no metadata found")
+
+// Media describes an audio or video item played inline in the listing.
+type Media struct {
+	// Kind is either MediaAudio or MediaVideo.
+	Kind string
+	// ContentType is the media type of the file, such as video/mp4.
+	ContentType string
+	// Duration, Width and Height are read from the file's container headers
+	// when media metadata is enabled and the format is supported.
+	Duration time.Duration
+	Width    int
+	Height   int
+}
+
+// validateMedia checks that the players are enabled when media metadata is
+// to be read.
+func (c Config) validateMedia() error {
+	if c.MediaMetadata && !c.MediaPlayers {
+		return fmt.Errorf("media_players is required for media_metadata")
+	}
+
+	return nil
+}
+
+// media returns the media of an item played inline in the listing, or nil
+// if it isn't audio or video or the players are disabled.
+func (c Config) media(item Item) *Media {
+	if !c.MediaPlayers || item.IsDir {
+		return nil
+	}
+
+	t := item.MimeType
+	if t == "" {
+		t = baseType(contentType(item.Name))
+	}
+	switch {
+	case strings.HasPrefix(t, MediaAudio+"/"):
+		return &Media{Kind: MediaAudio, ContentType: t}
+	case strings.HasPrefix(t, MediaVideo+"/"):
+		return &Media{Kind: MediaVideo, ContentType: t}
+	default:
+		return nil
+	}
+}
+
+// Info returns the duration and resolution of the media for display, such
+// as "3:25, 1920x1080", or an empty string if neither is known.
+func (m Media) Info() string {
+	var parts []string
+	if length := m.Length(); length != "" {
+		parts = append(parts, length)
+	}
+	if resolution := m.Resolution(); resolution != "" {
+		parts = append(parts, resolution)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Length returns the duration of the media as m:ss, or h:mm:ss for an hour or
+// more, or an empty string if it isn't known.
+func (m Media) Length() string {
+	if m.Duration <= 0 {
+		return ""
+	}
+
+	seconds := int(m.Duration.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// Resolution returns the dimensions of a video, such as 1920x1080, or an
+// empty string if they aren't known.
+func (m Media) Resolution() string {
+	if m.Width <= 0 || m.Height <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%dx%d", m.Width, m.Height)
+}
+
+// probeMedia reads the duration and resolution of the media items of a
+// listing from their container headers, if enabled. Files that can't be read
+// or aren't supported are listed without them.
+func (i Indexer) probeMedia(ctx context.Context, data *Data) {
+	reader, ok := i.Source.(FileReader)
+	if !i.Cfg.MediaMetadata || !ok {
+		return
+	}
+
+	for _, item := range data.Items {
+		if item.Media == nil {
+			continue
+		}
+
+		file, err := reader.Open(ctx, data.Path, item.Name)
+		if err != nil {
+			log.Warnf("Unable to read %s in %s for its metadata: %v", item.Name, data.Path, err)
+			continue
+		}
+
+		err = readMediaMetadata(file, item.Name, item.Media)
+		file.Close()
+		if err != nil {
+			log.Debugf("Unable to read the metadata of %s in %s: %v", item.Name, data.Path, err)
+		}
+	}
+}
+
+// readMediaMetadata reads the duration and resolution of a media file into
+// m, by the container format of its extension: MP4 and QuickTime, WAV and
+// FLAC are supported.
+func readMediaMetadata(r io.Reader, name string, m *Media) error {
+	// Files that can be seeked, such as local files, are read as far as
+	// needed. Others are only read up to a limit.
+	if _, ok := r.(io.Seeker); !ok {
+		r = io.LimitReader(r, maxMediaProbe)
+	}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp4", ".m4a", ".m4v", ".mov":
+		return readMP4Metadata(r, m)
+	case ".wav":
+		return readWAVMetadata(r, m)
+	case ".flac":
+		return readFLACMetadata(r, m)
+	default:
+		return fmt.Errorf("unsupported format")
+	}
+}
+
+// skip skips n bytes of r, seeking if possible.
+func skip(r io.Reader, n int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		return err
+	}
+
+	_, err := io.CopyN(io.Discard, r, n)
+
+	return err
+}
+
+// readMP4Metadata reads the duration from the movie header and the largest
+// track dimensions from the track headers of an MP4 or QuickTime file's moov
+// box.
+func readMP4Metadata(r io.Reader, m *Media) error {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return errMediaMetadata
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		if size == 1 {
+			var large [8]byte
+			if _, err := io.ReadFull(r, large[:]); err != nil {
+				return errMediaMetadata
+			}
+			size = int64(binary.BigEndian.Uint64(large[:])) // #nosec G115 -- checked below
+			headerSize = 16
+		}
+		if size != 0 && size < headerSize {
+			return fmt.Errorf("invalid box size %d", size)
+		}
+
+		if string(header[4:]) != "moov" {
+			// A box extending to the end of the file can only be the last.
+			if size == 0 {
+				return errMediaMetadata
+			}
+			if err := skip(r, size-headerSize); err != nil {
+				return errMediaMetadata
+			}
+
+			continue
+		}
+
+		if size == 0 || size-headerSize > maxMediaHeader {
+			return fmt.Errorf("moov box too large")
+		}
+		moov := make([]byte, size-headerSize)
+		if _, err := io.ReadFull(r, moov); err != nil {
+			return fmt.Errorf("unable to read moov box: %w", err)
+		}
+		parseMP4Boxes(moov, m)
+
+		return nil
+	}
+}
+
+// parseMP4Boxes parses the boxes of a moov box and its trak boxes.
+func parseMP4Boxes(b []byte, m *Media) {
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b[:4]))
+		if size < 8 || size > len(b) {
+			return
+		}
+		box, body := string(b[4:8]), b[8:size]
+		b = b[size:]
+
+		switch box {
+		case "trak":
+			parseMP4Boxes(body, m)
+		case "mvhd":
+			parseMVHD(body, m)
+		case "tkhd":
+			parseTKHD(body, m)
+		}
+	}
+}
+
+// parseMVHD reads the duration from a movie header box.
+func parseMVHD(b []byte, m *Media) {
+	var timescale, duration uint64
+	switch {
+	case len(b) >= 32 && b[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(b[20:24]))
+		duration = binary.BigEndian.Uint64(b[24:32])
+	case len(b) >= 20:
+		timescale = uint64(binary.BigEndian.Uint32(b[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+	default:
+		return
+	}
+	if timescale == 0 {
+		return
+	}
+
+	m.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// parseTKHD reads the dimensions from a track header box, which are zero for
+// audio tracks. The largest track of a file is kept.
+func parseTKHD(b []byte, m *Media) {
+	// The dimensions are the last fields, in 16.16 fixed point.
+	offset := 76
+	if len(b) > 0 && b[0] == 1 {
+		offset = 88
+	}
+	if len(b) < offset+8 {
+		return
+	}
+
+	width := int(binary.BigEndian.Uint32(b[offset:offset+4]) >> 16)
+	height := int(binary.BigEndian.Uint32(b[offset+4:offset+8]) >> 16)
+	if width*height > m.Width*m.Height {
+		m.Width, m.Height = width, height
+	}
+}
+
+// readWAVMetadata reads the duration of a WAV file from the byte rate of its
+// fmt chunk and the size of its data chunk.
+func readWAVMetadata(r io.Reader, m *Media) error {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[:4]) != "RIFF" ||
+		string(header[8:]) != "WAVE" {
+		return errMediaMetadata
+	}
+
+	var byteRate uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return errMediaMetadata
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch string(chunk[:4]) {
+		case "fmt ":
+			if size < 16 || size > maxMediaHeader {
+				return fmt.Errorf("invalid fmt chunk size %d", size)
+			}
+			format := make([]byte, size)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return errMediaMetadata
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+			if err := skip(r, size%2); err != nil {
+				return errMediaMetadata
+			}
+
+			continue
+		case "data":
+			if byteRate == 0 {
+				return errMediaMetadata
+			}
+			m.Duration = time.Duration(float64(size) / float64(byteRate) * float64(time.Second))
+
+			return nil
+		}
+
+		// Chunks are padded to an even size.
+		if err := skip(r, size+size%2); err != nil {
+			return errMediaMetadata
+		}
+	}
+}
+
+// readFLACMetadata reads the duration of a FLAC file from the sample rate and
+// sample count of its STREAMINFO block, which comes first.
+func readFLACMetadata(r io.Reader, m *Media) error {
+	var header [8 + 34]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || !bytes.Equal(header[:4], []byte("fLaC")) ||
+		header[4]&0x7f != 0 {
+		return errMediaMetadata
+	}
+
+	info := header[8:]
+	sampleRate := uint64(info[10])<<12 | uint64(info[11])<<4 | uint64(info[12])>>4
+	samples := uint64(info[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 {
+		return errMediaMetadata
+	}
+
+	m.Duration = time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+
+	return nil
+}
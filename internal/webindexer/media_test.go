@@ -0,0 +1,222 @@
+package webindexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mp4Box returns an MP4 box of the given type and body.
+func mp4Box(box string, body ...[]byte) []byte {
+	content := bytes.Join(body, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content))) // #nosec G115
+	b = append(b, box...)
+
+	return append(b, content...)
+}
+
+// testMP4 returns an MP4 file with the moov box after the media data, as
+// written by most encoders.
+func testMP4(duration, timescale uint32, width, height uint16) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+
+	tkhd := func(width, height uint16) []byte {
+		b := make([]byte, 84)
+		binary.BigEndian.PutUint32(b[76:], uint32(width)<<16)
+		binary.BigEndian.PutUint32(b[80:], uint32(height)<<16)
+		return b
+	}
+
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2")),
+		mp4Box("mdat", make([]byte, 4096)),
+		mp4Box("moov",
+			mp4Box("mvhd", mvhd),
+			mp4Box("trak", mp4Box("tkhd", tkhd(0, 0))),
+			mp4Box("trak", mp4Box("tkhd", tkhd(width, height)), mp4Box("mdia")),
+		),
+	}, nil)
+}
+
+// testWAV returns a WAV file of 16-bit stereo samples at 44.1 kHz.
+func testWAV(seconds int) []byte {
+	byteRate := 44100 * 2 * 2
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+seconds*byteRate)) // #nosec G115
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint32(b, 44100)
+	b = binary.LittleEndian.AppendUint32(b, uint32(byteRate)) // #nosec G115
+	b = binary.LittleEndian.AppendUint16(b, 4)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(seconds*byteRate)) // #nosec G115
+
+	return b
+}
+
+// testFLAC returns the header of a FLAC file with the given sample count at
+// 48 kHz.
+func testFLAC(samples uint64) []byte {
+	info := make([]byte, 34)
+	sampleRate := uint64(48000)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	info[12] = byte(sampleRate<<4) | 0x02
+	info[13] = 0xf0 | byte(samples>>32)
+	binary.BigEndian.PutUint32(info[14:], uint32(samples)) // #nosec G115
+
+	b := append([]byte("fLaC"), 0x80, 0, 0, 34)
+
+	return append(b, info...)
+}
+
+// streamReader hides the Seek method of a reader, like an S3 object body.
+type streamReader struct {
+	io.Reader
+}
+
+func TestConfigMedia(t *testing.T) {
+	cfg := Config{MediaPlayers: true}
+
+	assert.Equal(t, &Media{Kind: MediaVideo, ContentType: "video/mp4"}, cfg.media(Item{Name: "clip.MP4"}))
+	assert.Equal(t, &Media{Kind: MediaVideo, ContentType: "video/webm"}, cfg.media(Item{Name: "clip.webm"}))
+	assert.Equal(t, &Media{Kind: MediaAudio, ContentType: "audio/mpeg"}, cfg.media(Item{Name: "song.mp3"}))
+	assert.Equal(t, &Media{Kind: MediaAudio, ContentType: "audio/flac"}, cfg.media(Item{Name: "song.flac"}))
+	assert.Nil(t, cfg.media(Item{Name: "notes.txt"}))
+	assert.Nil(t, cfg.media(Item{Name: "clips.mp4", IsDir: true}))
+	assert.Nil(t, Config{}.media(Item{Name: "clip.mp4"}))
+}
+
+func TestValidateMedia(t *testing.T) {
+	assert.NoError(t, Config{}.validateMedia())
+	assert.NoError(t, Config{MediaPlayers: true, MediaMetadata: true}.validateMedia())
+	assert.EqualError(t, Config{MediaMetadata: true}.validateMedia(), "media_players is required for media_metadata")
+}
+
+func TestMediaInfo(t *testing.T) {
+	assert.Equal(t, "", Media{}.Info())
+	assert.Equal(t, "0:07", Media{Duration: 7400 * time.Millisecond}.Info())
+	assert.Equal(t, "3:25, 1920x1080", Media{Duration: 205 * time.Second, Width: 1920, Height: 1080}.Info())
+	assert.Equal(t, "1:02:03", Media{Duration: time.Hour + 2*time.Minute + 3*time.Second}.Length())
+	assert.Equal(t, "640x480", Media{Width: 640, Height: 480}.Info())
+	assert.Equal(t, "", Media{Width: 640}.Resolution())
+}
+
+func TestReadMediaMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		want    Media
+		wantErr string
+	}{
+		{
+			name:    "mp4",
+			file:    "clip.mp4",
+			content: testMP4(90000, 1000, 1280, 720),
+			want:    Media{Duration: 90 * time.Second, Width: 1280, Height: 720},
+		},
+		{
+			name:    "m4a without video",
+			file:    "song.m4a",
+			content: testMP4(44100*30, 44100, 0, 0),
+			want:    Media{Duration: 30 * time.Second},
+		},
+		{
+			name:    "wav",
+			file:    "sound.wav",
+			content: testWAV(3),
+			want:    Media{Duration: 3 * time.Second},
+		},
+		{
+			name:    "flac",
+			file:    "song.flac",
+			content: testFLAC(48000 * 125),
+			want:    Media{Duration: 125 * time.Second},
+		},
+		{
+			name:    "mp4 without moov",
+			file:    "broken.mp4",
+			content: mp4Box("ftyp", []byte("isom")),
+			wantErr: "no metadata found",
+		},
+		{
+			name:    "not a wav",
+			file:    "fake.wav",
+			content: []byte("not a WAV file"),
+			wantErr: "no metadata found",
+		},
+		{
+			name:    "unsupported",
+			file:    "song.mp3",
+			content: []byte("ID3"),
+			wantErr: "unsupported format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readers := map[string]io.Reader{
+				"seeker": bytes.NewReader(tt.content),
+				"stream": streamReader{bytes.NewReader(tt.content)},
+			}
+			for name, r := range readers {
+				var m Media
+				err := readMediaMetadata(r, tt.file, &m)
+				if tt.wantErr != "" {
+					assert.EqualError(t, err, tt.wantErr, name)
+					continue
+				}
+				require.NoError(t, err, name)
+				assert.Equal(t, tt.want, m, name)
+			}
+		})
+	}
+}
+
+func TestGenerate_MediaPlayers(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "clip.mp4"), testMP4(65000, 1000, 640, 360), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "song.mp3"), []byte("ID3"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("hello"), 0o644))
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.Formats = []string{"html", "json"}
+	cfg.MediaPlayers = true
+	cfg.MediaMetadata = true
+	cfg.Minify = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index,
+			`<video controls preload="none"><source src="clip.mp4" type="video/mp4"></video>`+
+				`<span class="media-info">1:05, 640x360</span>`)
+		assert.Contains(t, index,
+			`<audio controls preload="none"><source src="song.mp3" type="audio/mpeg"></audio></div>`)
+		assert.NotContains(t, index, `src="notes.txt"`)
+
+		content, err := os.ReadFile(filepath.Join(sourceDir, "index.json"))
+		require.NoError(t, err)
+		var listing jsonListing
+		require.NoError(t, json.Unmarshal(content, &listing))
+		require.Len(t, listing.Items, 3)
+		duration := 65.0
+		assert.Equal(t, &jsonMedia{
+			Type: "video", ContentType: "video/mp4", Duration: &duration, Width: 640, Height: 360,
+		}, listing.Items[0].Media)
+		assert.Nil(t, listing.Items[1].Media)
+	})
+}
@@ -0,0 +1,98 @@
+package webindexer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// maxNoteSize is the largest header or readme file that is rendered. Larger
+// files are still listed, but not shown on the index page.
+const maxNoteSize = 1 << 20
+
+// Notes are the header and readme files of a directory, rendered to HTML to
+// be shown above and below its listing.
+type Notes struct {
+	Header template.HTML
+	Readme template.HTML
+}
+
+// isNoteFile reports whether name is one of the configured header or readme
+// files.
+func (c Config) isNoteFile(name string) bool {
+	return contains(c.HeaderFiles, name) || contains(c.ReadmeFiles, name)
+}
+
+// readNotes renders the first header and readme file of a directory that
+// exists. The read function returns the content of a file, or false if it
+// doesn't exist or is too large.
+func (c Config) readNotes(dir string, read func(name string) ([]byte, bool, error)) (Notes, error) {
+	var notes Notes
+	if c.statsOnly {
+		return notes, nil
+	}
+
+	files := []struct {
+		names []string
+		note  *template.HTML
+	}{
+		{c.HeaderFiles, &notes.Header},
+		{c.ReadmeFiles, &notes.Readme},
+	}
+
+	for _, f := range files {
+		for _, name := range f.names {
+			content, ok, err := read(name)
+			if err != nil {
+				return Notes{}, fmt.Errorf("unable to read %s in %s: %w", name, dir, err)
+			}
+			if !ok {
+				continue
+			}
+
+			*f.note, err = renderNote(name, content)
+			if err != nil {
+				return Notes{}, fmt.Errorf("unable to render %s in %s: %w", name, dir, err)
+			}
+			log.Debugf("Rendered %s in %s", name, dir)
+
+			break
+		}
+	}
+
+	return notes, nil
+}
+
+// notePolicy sanitizes rendered notes, allowing the formatting and links
+// found in typical readme files but no scripts or styles.
+var notePolicy = bluemonday.UGCPolicy()
+
+// renderNote renders a header or readme file to sanitized HTML. Markdown and
+// HTML files are rendered by their extension, anything else is shown as
+// preformatted text.
+func renderNote(name string, content []byte) (template.HTML, error) {
+	var rendered []byte
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		var buf bytes.Buffer
+		md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+		if err := md.Convert(content, &buf); err != nil {
+			return "", fmt.Errorf("unable to convert Markdown: %w", err)
+		}
+		rendered = buf.Bytes()
+	case ".html", ".htm":
+		rendered = content
+	default:
+		return template.HTML("<pre>" + html.EscapeString(string(content)) + "</pre>"), nil // #nosec G203
+	}
+
+	return template.HTML(notePolicy.SanitizeBytes(rendered)), nil // #nosec G203
+}
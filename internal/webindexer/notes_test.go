@@ -0,0 +1,167 @@
+package webindexer
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderNote(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "markdown",
+			file:     "README.md",
+			content:  "# Downloads\n\nSee [the docs](https://example.com).\n\n<script>alert(1)</script>\n",
+			contains: []string{"<h1", "Downloads</h1>", `<a href="https://example.com"`},
+			excludes: []string{"<script>"},
+		},
+		{
+			name:     "markdown javascript link",
+			file:     "README.markdown",
+			content:  "[click](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+		{
+			name:     "html",
+			file:     "HEADER.html",
+			content:  `<p onclick="alert(1)">Welcome <b>home</b></p><script>alert(1)</script>`,
+			contains: []string{"<p>Welcome <b>home</b></p>"},
+			excludes: []string{"onclick", "<script>"},
+		},
+		{
+			name:     "text",
+			file:     "FOOTER.txt",
+			content:  "Mirrors <updated> nightly",
+			contains: []string{"<pre>Mirrors &lt;updated&gt; nightly</pre>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := renderNote(tt.file, []byte(tt.content))
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, string(rendered), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, string(rendered), s)
+			}
+		})
+	}
+}
+
+func TestReadNotes(t *testing.T) {
+	cfg := Config{
+		HeaderFiles: []string{"HEADER.md", "HEADER.html"},
+		ReadmeFiles: []string{"README.md", "FOOTER.txt"},
+	}
+	files := map[string]string{
+		"HEADER.html": "<p>header</p>",
+		"FOOTER.txt":  "footer",
+	}
+
+	notes, err := cfg.readNotes("/dir", func(name string) ([]byte, bool, error) {
+		content, ok := files[name]
+		return []byte(content), ok, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "<p>header</p>", string(notes.Header))
+	assert.Equal(t, "<pre>footer</pre>", string(notes.Readme))
+
+	// The first file found is used
+	files["HEADER.md"] = "*preferred*"
+	notes, err = cfg.readNotes("/dir", func(name string) ([]byte, bool, error) {
+		content, ok := files[name]
+		return []byte(content), ok, nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(notes.Header), "<em>preferred</em>")
+}
+
+func TestLocalBackendList_Notes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("**Read me**"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "HEADER.txt"), []byte("Header"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "FOOTER.txt"),
+		[]byte(strings.Repeat("x", maxNoteSize+1)), 0o644))
+
+	backend := LocalBackend{path: dir, cfg: Config{
+		DateFormat:  "2006-01-02",
+		IndexFile:   "index.html",
+		HeaderFiles: []string{"HEADER.txt"},
+		ReadmeFiles: []string{"FOOTER.txt", "README.md"},
+	}}
+
+	listing, err := backend.List(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, "<pre>Header</pre>", string(listing.Notes.Header))
+	// The footer is too large, so the readme is used instead
+	assert.Contains(t, string(listing.Notes.Readme), "<strong>Read me</strong>")
+	// The files are still listed
+	assert.Len(t, listing.Items, 3)
+}
+
+func TestS3BackendList_Notes(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			Source:      "s3://test-bucket",
+			IndexFile:   "index.html",
+			ReadmeFiles: []string{"README.md"},
+		},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("docs/README.md"), Size: aws.Int64(12), LastModified: aws.Time(time.Now())},
+			{Key: aws.String("docs/file.txt"), Size: aws.Int64(4), LastModified: aws.Time(time.Now())},
+		},
+	}, nil)
+	mockSvc.On("GetObjectWithContext", &s3.GetObjectInput{
+		Bucket: aws.String("test-bucket"),
+		Key:    aws.String("docs/README.md"),
+	}).Return(&s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader("# Docs")),
+	}, nil)
+
+	listing, err := backend.List(context.Background(), "docs/")
+	require.NoError(t, err)
+	assert.Contains(t, string(listing.Notes.Readme), "Docs</h1>")
+	assert.Len(t, listing.Items, 2)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestGenerate_Notes(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "file.txt"), []byte("content"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "HEADER.html"), []byte("<p>Welcome</p>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "README.md"), []byte("Read *me*"), 0o644))
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.HeaderFiles = []string{"HEADER.html"}
+	cfg.ReadmeFiles = []string{"README.md"}
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<div class="note header"><p>Welcome</p></div>`)
+		assert.Contains(t, index, `<div class="note readme"><p>Read <em>me</em></p>`)
+	})
+}
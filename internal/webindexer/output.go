@@ -0,0 +1,362 @@
+package webindexer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Format is an output format for directory listings.
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "md"
+)
+
+// contentTypeHTML is the content type of HTML index files, as uploaded to S3
+// since the first release.
+const contentTypeHTML = "text/html"
+
+// outputKind is the kind of file an output is.
+type outputKind int
+
+const (
+	// outputIndex is a listing of a directory in one of the formats.
+	outputIndex outputKind = iota
+	// outputFile is a file generated alongside the listings, like a feed,
+	// a checksum file, a thumbnail or the page of a single file.
+	outputFile
+)
+
+// output describes a file generated for each indexed directory.
+type output struct {
+	kind        outputKind
+	format      Format
+	file        string
+	template    string
+	contentType string
+}
+
+// OutputConfig configures a file generated for each directory. File and
+// Template default to those of the format.
+type OutputConfig struct {
+	Format   string `yaml:"format"   mapstructure:"format"`
+	File     string `yaml:"file"     mapstructure:"file"`
+	Template string `yaml:"template" mapstructure:"template"`
+}
+
+// FormatValue returns the output's format.
+func (o OutputConfig) FormatValue() Format {
+	return parseFormat(o.Format)
+}
+
+func parseFormat(format string) Format {
+	return Format(strings.ToLower(strings.TrimSpace(format)))
+}
+
+// FormatValues returns the configured output formats. Without any formats or
+// outputs configured, HTML is generated. Unknown formats are returned as-is
+// for Validate to report.
+func (c Config) FormatValues() []Format {
+	if len(c.Formats) == 0 && len(c.Outputs) == 0 {
+		return []Format{FormatHTML}
+	}
+
+	formats := make([]Format, 0, len(c.Formats))
+	for _, f := range c.Formats {
+		formats = append(formats, parseFormat(f))
+	}
+
+	return formats
+}
+
+// outputs returns the files to generate for each directory: those configured
+// in Outputs, followed by the defaults for any other format in Formats.
+func (c Config) outputs() []output {
+	outputs := make([]output, 0, len(c.Outputs)+len(c.Formats))
+	configured := make(map[Format]bool, len(c.Outputs))
+	for _, oc := range c.Outputs {
+		o := c.defaultOutput(oc.FormatValue())
+		if oc.File != "" {
+			o.file = oc.File
+		}
+		if oc.Template != "" {
+			o.template = oc.Template
+		}
+		outputs = append(outputs, o)
+		configured[o.format] = true
+	}
+
+	for _, format := range c.FormatValues() {
+		if !configured[format] {
+			outputs = append(outputs, c.defaultOutput(format))
+			configured[format] = true
+		}
+	}
+
+	return outputs
+}
+
+// hasFormat reports whether a listing is written in format.
+func (c Config) hasFormat(format Format) bool {
+	for _, o := range c.outputs() {
+		if o.format == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultOutput returns the file generated for a format unless configured
+// otherwise.
+func (c Config) defaultOutput(format Format) output {
+	switch format {
+	case FormatHTML:
+		return output{format: format, file: c.IndexFile, template: c.Template, contentType: contentTypeHTML}
+	case FormatJSON:
+		return output{
+			format:      format,
+			file:        strings.TrimSuffix(c.IndexFile, path.Ext(c.IndexFile)) + ".json",
+			contentType: "application/json",
+		}
+	case FormatMarkdown:
+		return output{format: format, file: "INDEX.md", contentType: "text/markdown; charset=utf-8"}
+	default:
+		return output{format: format}
+	}
+}
+
+// validateOutputs checks that all outputs have a known format and that no
+// two outputs are written to the same file.
+func (c Config) validateOutputs() error {
+	files := make(map[string]bool)
+	for _, o := range c.outputs() {
+		switch o.format {
+		case FormatHTML, FormatJSON, FormatMarkdown:
+		default:
+			return fmt.Errorf("formats must be one of: html, json, md")
+		}
+
+		if files[o.file] {
+			return fmt.Errorf("outputs must not share a file name: %s", o.file)
+		}
+		files[o.file] = true
+	}
+
+	return nil
+}
+
+// shouldSkip reports whether a file should be left out of listings, either
+// because it is configured to be skipped or because it is one of the
+// generated output files.
+func (c Config) shouldSkip(name string) bool {
+	for _, o := range c.outputs() {
+		if shouldSkip(name, o.file, c.Skips) {
+			return true
+		}
+		if c.PageSize > 0 && o.format == FormatHTML && isPageFile(name, o.file) {
+			return true
+		}
+	}
+
+	if o, ok := c.feedOutput(); ok && shouldSkip(name, o.file, nil) {
+		return true
+	}
+
+	if c.Search && shouldSkip(name, searchIndexFile, nil) {
+		return true
+	}
+
+	return c.isChecksumFile(name)
+}
+
+// render renders the listing for a directory in the given output's format,
+// with the output's template if it has one.
+func (i Indexer) render(o output, data Data) ([]byte, error) {
+	var content []byte
+	var err error
+	switch {
+	case o.template != "":
+		content, err = renderTemplate(o, data)
+	case o.format == FormatJSON:
+		content, err = renderJSON(data)
+	case o.format == FormatMarkdown:
+		content, err = renderMarkdown(o, data)
+	case o.format == FormatHTML:
+		log.Debugf("Using %s theme template for %s", i.Cfg.Theme, data.Path)
+		content, err = executeTemplate(o.format, getThemeTemplate(i.Cfg.Theme), data)
+	default:
+		err = fmt.Errorf("unsupported format %q", o.format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.format == FormatHTML && i.Cfg.Minify {
+		content = []byte(minifyHTML(string(content)))
+	}
+
+	return content, nil
+}
+
+// renderTemplate renders the listing with the output's custom template file.
+func renderTemplate(o output, data Data) ([]byte, error) {
+	log.Debugf("Using custom template %s for %s", o.template, data.Path)
+	templBytes, err := os.ReadFile(o.template)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeTemplate(o.format, string(templBytes), data)
+}
+
+// executeTemplate renders the listing with the given template. HTML
+// templates escape their output, other formats are rendered as plain text.
+func executeTemplate(format Format, templStr string, data Data) ([]byte, error) {
+	generated := new(bytes.Buffer)
+	if format == FormatHTML {
+		tmpl, err := template.New("index").Parse(templStr)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(generated, data); err != nil {
+			return nil, err
+		}
+	} else {
+		tmpl, err := texttemplate.New("index").Parse(templStr)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(generated, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return generated.Bytes(), nil
+}
+
+// jsonListing is the structure of JSON index files.
+type jsonListing struct {
+	Title  string     `json:"title,omitempty"`
+	Path   string     `json:"path"`
+	Parent string     `json:"parent,omitempty"`
+	Items  []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Size         *int64            `json:"size,omitempty"`
+	LastModified *time.Time        `json:"last_modified,omitempty"`
+	URL          string            `json:"url"`
+	MimeType     string            `json:"mime_type,omitempty"`
+	Kind         string            `json:"kind,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Checksums    map[string]string `json:"checksums,omitempty"`
+	Companions   []jsonCompanion   `json:"companions,omitempty"`
+	Verified     *bool             `json:"verified,omitempty"`
+	ThumbnailURL string            `json:"thumbnail_url,omitempty"`
+	Media        *jsonMedia        `json:"media,omitempty"`
+	Items        []jsonItem        `json:"items,omitempty"`
+}
+
+type jsonMedia struct {
+	Type        string   `json:"type"`
+	ContentType string   `json:"content_type"`
+	Duration    *float64 `json:"duration,omitempty"`
+	Width       int      `json:"width,omitempty"`
+	Height      int      `json:"height,omitempty"`
+}
+
+type jsonCompanion struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// jsonItems converts items for the JSON listing, including the contents of
+// directories in tree mode.
+func jsonItems(items []Item) []jsonItem {
+	converted := make([]jsonItem, 0, len(items))
+	for _, item := range items {
+		ji := jsonItem{
+			Name:        item.Name,
+			Type:        "file",
+			URL:         item.URL,
+			Description: item.Description,
+		}
+		ji.MimeType = item.MimeType
+		ji.Kind = item.Kind
+		ji.ThumbnailURL = item.ThumbnailURL
+		if m := item.Media; m != nil {
+			ji.Media = &jsonMedia{Type: m.Kind, ContentType: m.ContentType, Width: m.Width, Height: m.Height}
+			if m.Duration > 0 {
+				duration := m.Duration.Seconds()
+				ji.Media.Duration = &duration
+			}
+		}
+		// Directories only have a size of their contents with stats, not
+		// the size of the directory entry itself
+		if !item.IsDir || item.HasStats {
+			size := item.SizeBytes
+			ji.Size = &size
+		}
+		if item.IsDir {
+			ji.Type = "directory"
+		}
+		for _, c := range item.Companions {
+			ji.Companions = append(ji.Companions, jsonCompanion{Name: c.Name, Size: c.SizeBytes, URL: c.URL})
+		}
+		if item.Verified || item.ChecksumMismatch {
+			verified := item.Verified
+			ji.Verified = &verified
+		}
+		if len(item.Checksums) > 0 {
+			ji.Checksums = make(map[string]string, len(item.Checksums))
+			for _, c := range item.Checksums {
+				ji.Checksums[string(c.Algorithm)] = c.Sum
+			}
+		}
+		if !item.ModTime.IsZero() {
+			modTime := item.ModTime.UTC()
+			ji.LastModified = &modTime
+		}
+		if len(item.Items) > 0 {
+			ji.Items = jsonItems(item.Items)
+		}
+		converted = append(converted, ji)
+	}
+
+	return converted
+}
+
+// renderJSON renders the listing as JSON, with raw sizes and RFC 3339 times
+// so it can be consumed by scripts.
+func renderJSON(data Data) ([]byte, error) {
+	listing := jsonListing{
+		Title: data.Title,
+		Path:  data.RelativePath,
+		Items: jsonItems(data.Items),
+	}
+	if data.HasParent {
+		listing.Parent = data.Parent
+	}
+
+	content, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode JSON listing: %w", err)
+	}
+
+	return append(content, '\n'), nil
+}
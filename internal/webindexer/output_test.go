@@ -0,0 +1,219 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigOutputs(t *testing.T) {
+	cfg := Config{IndexFile: "index.html"}
+	assert.Equal(t, []output{{format: FormatHTML, file: "index.html", contentType: contentTypeHTML}}, cfg.outputs())
+
+	cfg.Formats = []string{"html", " JSON ", "md"}
+	assert.Equal(t, []output{
+		{format: FormatHTML, file: "index.html", contentType: contentTypeHTML},
+		{format: FormatJSON, file: "index.json", contentType: "application/json"},
+		{format: FormatMarkdown, file: "INDEX.md", contentType: "text/markdown; charset=utf-8"},
+	}, cfg.outputs())
+}
+
+func TestConfigOutputs_Configured(t *testing.T) {
+	cfg := Config{
+		IndexFile: "index.html",
+		Template:  "custom.html.tmpl",
+		Formats:   []string{"html", "json"},
+		Outputs: []OutputConfig{
+			{Format: "json", File: "listing.json"},
+			{Format: "html", File: "plain.html", Template: "plain.html.tmpl"},
+		},
+	}
+
+	assert.Equal(t, []output{
+		{format: FormatJSON, file: "listing.json", contentType: "application/json"},
+		{format: FormatHTML, file: "plain.html", template: "plain.html.tmpl", contentType: contentTypeHTML},
+	}, cfg.outputs())
+
+	// Outputs without formats only generate the configured outputs
+	cfg.Formats = nil
+	cfg.Outputs = []OutputConfig{{Format: "json"}}
+	assert.Equal(t, []output{{format: FormatJSON, file: "index.json", contentType: "application/json"}}, cfg.outputs())
+}
+
+func TestConfigValidateOutputs(t *testing.T) {
+	cfg := Config{IndexFile: "index.html", Formats: []string{"html"}}
+	require.NoError(t, cfg.validateOutputs())
+
+	cfg.Outputs = []OutputConfig{{Format: "yaml"}}
+	require.EqualError(t, cfg.validateOutputs(), "formats must be one of: html, json, md")
+
+	cfg.Outputs = []OutputConfig{{Format: "json", File: "index.html"}}
+	require.EqualError(t, cfg.validateOutputs(), "outputs must not share a file name: index.html")
+}
+
+func TestConfigShouldSkip(t *testing.T) {
+	cfg := Config{IndexFile: "index.html", Formats: []string{"html", "json"}, Skips: []string{".git"}}
+
+	assert.True(t, cfg.shouldSkip("index.html"))
+	assert.True(t, cfg.shouldSkip("index.json"))
+	assert.True(t, cfg.shouldSkip(".git"))
+	assert.False(t, cfg.shouldSkip("data.json"))
+}
+
+func TestRenderJSON(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	data := Data{
+		Title:        "Index of /files",
+		RelativePath: "files/",
+		Parent:       "/",
+		HasParent:    true,
+		Items: []Item{
+			{Name: "docs/", URL: "docs/", IsDir: true, SizeBytes: 4096},
+			{Name: "src/", URL: "src/", IsDir: true, HasStats: true, SizeBytes: 2048},
+			{Name: "empty.txt", URL: "empty.txt"},
+			{Name: "release.tar.gz", URL: "release.tar.gz", Size: "1.00 MB", SizeBytes: 1048576, ModTime: modTime},
+		},
+	}
+
+	content, err := renderJSON(data)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"title": "Index of /files",
+		"path": "files/",
+		"parent": "/",
+		"items": [
+			{"name": "docs/", "type": "directory", "url": "docs/"},
+			{"name": "src/", "type": "directory", "size": 2048, "url": "src/"},
+			{"name": "empty.txt", "type": "file", "size": 0, "url": "empty.txt"},
+			{
+				"name": "release.tar.gz",
+				"type": "file",
+				"size": 1048576,
+				"last_modified": "2024-05-01T10:00:00Z",
+				"url": "release.tar.gz"
+			}
+		]
+	}`, string(content))
+}
+
+func TestRenderJSON_NoParent(t *testing.T) {
+	content, err := renderJSON(Data{RelativePath: "", Parent: "/"})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"path": "", "items": []}`, string(content))
+}
+
+func TestRender_Template(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "listing.txt.tmpl")
+	require.NoError(t, os.WriteFile(templateFile, []byte("{{range .Items}}{{.Name}} {{.URL}}\n{{end}}"), 0o644))
+
+	indexer := Indexer{Cfg: Config{Minify: true}}
+	data := Data{Items: []Item{{Name: "a&b.txt", URL: "a&b.txt"}}}
+
+	// Templates for formats other than HTML aren't escaped or minified
+	content, err := indexer.render(output{format: FormatJSON, template: templateFile}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "a&b.txt a&b.txt\n", string(content))
+
+	content, err = indexer.render(output{format: FormatHTML, template: templateFile}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "a&amp;b.txt a&amp;b.txt", string(content))
+}
+
+func TestGenerate_JSONFormat(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "subdir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "file.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "subdir", "nested.txt"), []byte("nested"), 0o644))
+
+	cfg := Config{
+		Source:     sourceDir,
+		Target:     targetDir,
+		Recursive:  true,
+		SortBy:     "name",
+		Order:      "asc",
+		IndexFile:  "index.html",
+		BasePath:   sourceDir,
+		DateFormat: "2006-01-02",
+		Formats:    []string{"html", "json"},
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.IndexesWritten)
+
+	assert.FileExists(t, filepath.Join(targetDir, "index.html"))
+	assert.FileExists(t, filepath.Join(targetDir, "subdir", "index.html"))
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "subdir", "index.json"))
+	require.NoError(t, err)
+
+	var listing jsonListing
+	require.NoError(t, json.Unmarshal(content, &listing))
+	require.Len(t, listing.Items, 1)
+	assert.Equal(t, "nested.txt", listing.Items[0].Name)
+	assert.Equal(t, "file", listing.Items[0].Type)
+	require.NotNil(t, listing.Items[0].Size)
+	assert.Equal(t, int64(6), *listing.Items[0].Size)
+	assert.NotNil(t, listing.Items[0].LastModified)
+
+	// The root listing has the file and the subdirectory
+	content, err = os.ReadFile(filepath.Join(targetDir, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &listing))
+	assert.Len(t, listing.Items, 2)
+}
+
+func TestGenerate_Outputs(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	templateFile := filepath.Join(t.TempDir(), "files.txt.tmpl")
+
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "file.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(templateFile, []byte("{{range .Items}}{{.Name}}\n{{end}}"), 0o644))
+
+	cfg := Config{
+		Source:     sourceDir,
+		Target:     targetDir,
+		SortBy:     "name",
+		Order:      "asc",
+		IndexFile:  "index.html",
+		BasePath:   sourceDir,
+		DateFormat: "2006-01-02",
+		Outputs: []OutputConfig{
+			{Format: "html"},
+			{Format: "json", File: "listing.json"},
+			{Format: "html", File: "files.txt", Template: templateFile},
+		},
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.IndexesWritten)
+
+	assert.FileExists(t, filepath.Join(targetDir, "index.html"))
+	assert.FileExists(t, filepath.Join(targetDir, "listing.json"))
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "files.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "file.txt\n", string(content))
+}
@@ -0,0 +1,132 @@
+package webindexer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Pagination describes the page of a directory listing split across several
+// pages.
+type Pagination struct {
+	// Current is the number of the page, starting at 1.
+	Current int
+	// Total is the number of pages.
+	Total int
+	// PrevURL and NextURL link to the previous and next pages. They are
+	// empty on the first and last pages.
+	PrevURL string
+	NextURL string
+	// Pages links to every page, for numbered pager controls.
+	Pages []PageLink
+}
+
+// PageLink is a link to a page of a listing.
+type PageLink struct {
+	Number  int
+	URL     string
+	Current bool
+}
+
+// page is the output and data for a page of a directory listing.
+type page struct {
+	output output
+	data   Data
+}
+
+// pageFile returns the file name of a page: the output's file for the first
+// page, then index-2.html, index-3.html and so on.
+func pageFile(file string, number int) string {
+	if number == 1 {
+		return file
+	}
+
+	ext := path.Ext(file)
+
+	return strings.TrimSuffix(file, ext) + "-" + strconv.Itoa(number) + ext
+}
+
+// isPageFile reports whether name is a page after the first of file.
+func isPageFile(name, file string) bool {
+	ext := path.Ext(file)
+	number, ok := strings.CutPrefix(path.Base(name), strings.TrimSuffix(file, ext)+"-")
+	if !ok {
+		return false
+	}
+	number, ok = strings.CutSuffix(number, ext)
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.ParseUint(number, 10, 0)
+
+	return err == nil
+}
+
+// removeStalePages removes the pages of an HTML listing after the last one,
+// left over from an earlier run when the listing was longer. Only done when
+// pagination is enabled, and if the target can remove files.
+func (i Indexer) removeStalePages(ctx context.Context, o output, data Data, count int) error {
+	if o.format != FormatHTML || i.Cfg.PageSize <= 0 {
+		return nil
+	}
+	remover, ok := i.target().(FileRemover)
+	if !ok {
+		return nil
+	}
+
+	for number := count + 1; ; number++ {
+		file := pageFile(o.file, number)
+		removed, err := remover.RemoveFile(ctx, data.RelativePath, file)
+		if err != nil {
+			return fmt.Errorf("unable to remove stale page %s for %s: %w", file, data.RelativePath, err)
+		}
+		if !removed {
+			return nil
+		}
+	}
+}
+
+// pages splits the listing of a directory into pages of the configured size.
+// Only HTML listings are split, other formats always list every item.
+func (i Indexer) pages(o output, data Data) []page {
+	size := i.Cfg.PageSize
+	if o.format != FormatHTML || size <= 0 || len(data.Items) <= size {
+		return []page{{output: o, data: data}}
+	}
+
+	total := (len(data.Items) + size - 1) / size
+	links := make([]PageLink, 0, total)
+	for number := 1; number <= total; number++ {
+		links = append(links, PageLink{Number: number, URL: pageFile(o.file, number)})
+	}
+
+	pages := make([]page, 0, total)
+	for number := 1; number <= total; number++ {
+		pagination := &Pagination{
+			Current: number,
+			Total:   total,
+			Pages:   append([]PageLink(nil), links...),
+		}
+		pagination.Pages[number-1].Current = true
+		if number > 1 {
+			pagination.PrevURL = links[number-2].URL
+		}
+		if number < total {
+			pagination.NextURL = links[number].URL
+		}
+
+		pageData := data
+		pageData.Items = data.Items[(number-1)*size : min(number*size, len(data.Items))]
+		pageData.Pagination = pagination
+
+		pageOutput := o
+		pageOutput.file = pageFile(o.file, number)
+
+		pages = append(pages, page{output: pageOutput, data: pageData})
+	}
+
+	return pages
+}
@@ -0,0 +1,161 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageFile(t *testing.T) {
+	assert.Equal(t, "index.html", pageFile("index.html", 1))
+	assert.Equal(t, "index-2.html", pageFile("index.html", 2))
+	assert.Equal(t, "listing-10", pageFile("listing", 10))
+}
+
+func TestIsPageFile(t *testing.T) {
+	assert.True(t, isPageFile("index-2.html", "index.html"))
+	assert.True(t, isPageFile("prefix/index-12.html", "index.html"))
+	assert.False(t, isPageFile("index.html", "index.html"))
+	assert.False(t, isPageFile("index-two.html", "index.html"))
+	assert.False(t, isPageFile("index-2.htm", "index.html"))
+	assert.False(t, isPageFile("myindex-2.html", "index.html"))
+}
+
+func TestPages(t *testing.T) {
+	items := []Item{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	indexer := Indexer{Cfg: Config{PageSize: 2}}
+	htmlOutput := output{format: FormatHTML, file: "index.html"}
+
+	pages := indexer.pages(htmlOutput, Data{Items: items})
+	require.Len(t, pages, 3)
+
+	assert.Equal(t, "index.html", pages[0].output.file)
+	assert.Equal(t, items[:2], pages[0].data.Items)
+	assert.Equal(t, 1, pages[0].data.Pagination.Current)
+	assert.Empty(t, pages[0].data.Pagination.PrevURL)
+	assert.Equal(t, "index-2.html", pages[0].data.Pagination.NextURL)
+
+	assert.Equal(t, "index-2.html", pages[1].output.file)
+	assert.Equal(t, &Pagination{
+		Current: 2,
+		Total:   3,
+		PrevURL: "index.html",
+		NextURL: "index-3.html",
+		Pages: []PageLink{
+			{Number: 1, URL: "index.html"},
+			{Number: 2, URL: "index-2.html", Current: true},
+			{Number: 3, URL: "index-3.html"},
+		},
+	}, pages[1].data.Pagination)
+
+	assert.Equal(t, items[4:], pages[2].data.Items)
+	assert.Empty(t, pages[2].data.Pagination.NextURL)
+
+	// Other formats and short listings aren't split
+	assert.Len(t, indexer.pages(output{format: FormatJSON, file: "index.json"}, Data{Items: items}), 1)
+	indexer.Cfg.PageSize = 5
+	single := indexer.pages(htmlOutput, Data{Items: items})
+	require.Len(t, single, 1)
+	assert.Nil(t, single[0].data.Pagination)
+}
+
+func TestGenerate_Pagination(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for n := range 5 {
+		name := filepath.Join(sourceDir, fmt.Sprintf("file%d.txt", n))
+		require.NoError(t, os.WriteFile(name, []byte("content"), 0o644))
+	}
+
+	cfg := Config{
+		Source:     sourceDir,
+		Target:     targetDir,
+		SortBy:     "name",
+		Order:      "asc",
+		IndexFile:  "index.html",
+		BasePath:   sourceDir,
+		DateFormat: "2006-01-02",
+		PageSize:   2,
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.IndexesWritten)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "index-2.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "file2.txt")
+	assert.NotContains(t, string(content), "file4.txt")
+	assert.Contains(t, string(content), `<a href="index.html" rel="prev">`)
+	assert.Contains(t, string(content), `<a href="index-3.html" rel="next">`)
+	assert.Contains(t, string(content), `<span class="current">2</span>`)
+
+	// Pages written to the source aren't listed on the next run
+	cfg.Target = sourceDir
+	assert.True(t, cfg.shouldSkip("index-3.html"))
+
+	// Pages past the end of a listing that got shorter are removed
+	require.NoError(t, os.Remove(filepath.Join(sourceDir, "file4.txt")))
+	require.NoError(t, os.Remove(filepath.Join(sourceDir, "file3.txt")))
+	_, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(targetDir, "index-2.html"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "index-3.html"))
+
+	// And so are every page after the first of a directory no longer indexed
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, ".skipindex"), nil, 0o644))
+	indexer.Cfg.SkipIndexFiles = []string{".skipindex"}
+	indexer.Source = &LocalBackend{path: sourceDir, cfg: indexer.Cfg}
+	_, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(targetDir, "index.html"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "index-2.html"))
+}
+
+func TestGenerate_PaginationSitemap(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for n := range 5 {
+		name := filepath.Join(sourceDir, fmt.Sprintf("file%d.txt", n))
+		require.NoError(t, os.WriteFile(name, []byte("content"), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.BaseURL = "https://example.com"
+	cfg.PageSize = 2
+	cfg.Sitemap = true
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "sitemap.xml"))
+	require.NoError(t, err)
+	var urlSet sitemapURLSet
+	require.NoError(t, xml.Unmarshal(content, &urlSet))
+
+	locs := make([]string, 0, len(urlSet.URLs))
+	for _, u := range urlSet.URLs {
+		locs = append(locs, u.Loc)
+	}
+	assert.Equal(t, []string{
+		"https://example.com/",
+		"https://example.com/index-2.html",
+		"https://example.com/index-3.html",
+	}, locs)
+}
@@ -0,0 +1,109 @@
+package webindexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Result summarizes a call to Generate.
+type Result struct {
+	// DirsScanned is the number of directories listed from the source.
+	DirsScanned int `json:"dirs_scanned"`
+	// IndexesWritten is the number of index files written to the target.
+	IndexesWritten int `json:"indexes_written"`
+	// IndexesUnchanged is the number of index files that already existed on
+	// the target with the same content and weren't written again.
+	IndexesUnchanged int `json:"indexes_unchanged"`
+	// IndexesSkipped is the number of directories without an index file
+	// because they were empty or contained a skipindex file.
+	IndexesSkipped int `json:"indexes_skipped"`
+	// FilesWritten is the number of other generated files written to the
+	// target, like feeds, sitemaps, checksum files, thumbnails and the pages
+	// of single files.
+	FilesWritten int `json:"files_written"`
+	// FilesUnchanged is the number of other generated files that already
+	// existed on the target with the same content.
+	FilesUnchanged int `json:"files_unchanged"`
+	// ItemsListed is the total number of items across all listings.
+	ItemsListed int `json:"items_listed"`
+	// BytesUploaded is the total size of the files written to the target.
+	BytesUploaded int64 `json:"bytes_uploaded"`
+	// NoIndexHits is the number of directories skipped due to a noindex file.
+	NoIndexHits int `json:"noindex_hits"`
+	// SkipIndexHits is the number of directories not indexed due to a
+	// skipindex file.
+	SkipIndexHits int `json:"skipindex_hits"`
+	// Interrupted is true if the run was cancelled or timed out.
+	Interrupted bool `json:"interrupted"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"-"`
+	// Failures are the directories that failed in keep-going mode.
+	Failures []Failure `json:"-"`
+	// Err is the error that stopped the run, if any.
+	Err error `json:"-"`
+}
+
+// reportError is the JSON representation of a failed path in the report.
+type reportError struct {
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error"`
+}
+
+// MarshalJSON encodes the result for the report file, with the duration in
+// seconds and errors as strings.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+
+	errs := make([]reportError, 0, len(r.Failures)+1)
+	for _, f := range r.Failures {
+		errs = append(errs, reportError{Path: f.Path, Error: f.Err.Error()})
+	}
+
+	var failedErr *FailedPathsError
+	if r.Err != nil && !errors.As(r.Err, &failedErr) {
+		errs = append(errs, reportError{Error: r.Err.Error()})
+	}
+
+	data, err := json.Marshal(struct {
+		result
+		DurationSeconds float64       `json:"duration_seconds"`
+		Errors          []reportError `json:"errors"`
+	}{
+		result:          result(r),
+		DurationSeconds: r.Duration.Seconds(),
+		Errors:          errs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode result: %w", err)
+	}
+
+	return data, nil
+}
+
+// WriteReport writes the result as JSON to the given local file.
+func (r Result) WriteReport(path string) error {
+	report, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode report: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(report, '\n'), 0o600); err != nil {
+		return fmt.Errorf("unable to write report %s: %w", path, err)
+	}
+	log.Debugf("Wrote report to %s", path)
+
+	return nil
+}
+
+// logSummary logs a one line summary of the result.
+func (r Result) logSummary() {
+	log.Infof("Scanned %d directories and %d items in %s: %d indexes written, %d unchanged, %d skipped; "+
+		"%d other files written, %d unchanged; %s uploaded",
+		r.DirsScanned, r.ItemsListed, r.Duration.Round(time.Millisecond), r.IndexesWritten,
+		r.IndexesUnchanged, r.IndexesSkipped, r.FilesWritten, r.FilesUnchanged, humanizeBytes(r.BytesUploaded))
+}
@@ -0,0 +1,100 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	failures := []Failure{{Path: "/root/private", Err: errors.New("permission denied")}}
+	result := Result{
+		DirsScanned:    3,
+		IndexesWritten: 2,
+		BytesUploaded:  2048,
+		NoIndexHits:    1,
+		Duration:       1500 * time.Millisecond,
+		Failures:       failures,
+		Err:            &FailedPathsError{Failures: failures},
+	}
+
+	encoded, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	assert.InDelta(t, 3, decoded["dirs_scanned"], 0)
+	assert.InDelta(t, 2, decoded["indexes_written"], 0)
+	assert.InDelta(t, 2048, decoded["bytes_uploaded"], 0)
+	assert.InDelta(t, 1, decoded["noindex_hits"], 0)
+	assert.InDelta(t, 1.5, decoded["duration_seconds"], 0)
+	assert.Equal(t, false, decoded["interrupted"])
+
+	// The aggregated keep-going error isn't repeated alongside its failures
+	assert.Equal(t, []any{
+		map[string]any{"path": "/root/private", "error": "permission denied"},
+	}, decoded["errors"])
+}
+
+func TestResultMarshalJSONFatalError(t *testing.T) {
+	encoded, err := json.Marshal(Result{Err: errors.New("unable to list S3 objects")})
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"errors":[{"error":"unable to list S3 objects"}]`)
+}
+
+func TestGenerate_ResultCountsFiles(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Recursive = true
+	cfg.BaseURL = "https://example.com"
+	cfg.Feed = "atom"
+	cfg.FeedItems = 10
+	cfg.Checksums = []string{"sha256"}
+	cfg.ChecksumFiles = true
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	// Only the two index.html files are indexes; the SHA256SUMS of each
+	// directory and the feed are counted as other files.
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.IndexesWritten)
+	assert.Equal(t, 3, result.FilesWritten)
+
+	result, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.IndexesWritten)
+	assert.Equal(t, 0, result.FilesWritten)
+	assert.Equal(t, 2, result.IndexesUnchanged)
+	assert.Equal(t, 3, result.FilesUnchanged)
+}
+
+func TestResultWriteReport(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	err := Result{DirsScanned: 1}.WriteReport(reportPath)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"dirs_scanned": 1`)
+	assert.Contains(t, string(content), `"errors": []`)
+}
@@ -0,0 +1,118 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/charmbracelet/log"
+)
+
+// maxRetryDelay caps the delay between two attempts, however many retries
+// have been made.
+const maxRetryDelay = 30 * time.Second
+
+// retryableCodes are S3 error codes for transient failures that are not
+// covered by the SDK's own retryable and throttle checks.
+var retryableCodes = map[string]struct{}{
+	"SlowDown":           {},
+	"ServiceUnavailable": {},
+	"InternalError":      {},
+	"RequestTimeout":     {},
+	"RequestCanceled":    {},
+}
+
+// withRetry calls fn until it succeeds, fails with a permanent error, or the
+// configured number of retries is exhausted. The delay between attempts grows
+// exponentially from the configured retry delay, with full jitter.
+func withRetry(ctx context.Context, cfg Config, op string, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 0 {
+				log.Infof("%s succeeded after %d retries", op, attempt)
+			}
+
+			return nil
+		}
+
+		// A cancelled run is never retried, and neither are errors such as
+		// access denied that will fail the same way every time.
+		if ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+
+		if attempt >= cfg.MaxRetries {
+			if attempt > 0 {
+				log.Errorf("%s failed after %d retries: %v", op, attempt, err)
+			}
+
+			return err
+		}
+
+		delay := retryDelay(cfg.RetryDelay, attempt)
+		log.Warnf("Retrying %s in %s (retry %d/%d): %v", op, delay.Round(time.Millisecond), attempt+1,
+			cfg.MaxRetries, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay returns a random delay between zero and base * 2^attempt,
+// capped at maxRetryDelay.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	ceiling := min(base, maxRetryDelay)
+	for range attempt {
+		if ceiling >= maxRetryDelay/2 {
+			ceiling = maxRetryDelay
+			break
+		}
+		ceiling *= 2
+	}
+
+	return rand.N(ceiling) + 1 // #nosec G404 -- jitter doesn't need a secure source
+}
+
+// isRetryable reports whether err is a transient backend error that may
+// succeed if the request is made again, such as throttling or a 5xx response.
+func isRetryable(err error) bool {
+	// A request timeout rather than a cancelled run, which withRetry checks
+	// separately.
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		switch reqErr.StatusCode() {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if _, ok := retryableCodes[awsErr.Code()]; ok {
+			return true
+		}
+
+		return request.IsErrorThrottle(awsErr) || request.IsErrorRetryable(awsErr)
+	}
+
+	return false
+}
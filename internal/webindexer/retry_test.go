@@ -0,0 +1,170 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func slowDownErr() error {
+	return awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "req-1")
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"slow down", slowDownErr(), true},
+		{"wrapped slow down", fmt.Errorf("unable to list S3 objects: %w", slowDownErr()), true},
+		{"throttling code", awserr.New("Throttling", "Rate exceeded", nil), true},
+		{"internal error status", awserr.NewRequestFailure(awserr.New("Unknown", "", nil), 500, ""), true},
+		{"request timeout", context.DeadlineExceeded, true},
+		{"access denied", awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, ""), false},
+		{"no such bucket", awserr.New(s3.ErrCodeNoSuchBucket, "", nil), false},
+		{"plain error", errors.New("permission denied"), false},
+		{"cancelled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	cfg := Config{MaxRetries: 3, RetryDelay: time.Microsecond}
+
+	t.Run("succeeds after transient errors", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), cfg, "test", func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return slowDownErr()
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), cfg, "test", func(context.Context) error {
+			calls++
+			return errors.New("permanent")
+		})
+		require.EqualError(t, err, "permanent")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), cfg, "test", func(context.Context) error {
+			calls++
+			return slowDownErr()
+		})
+		require.Error(t, err)
+		assert.Equal(t, cfg.MaxRetries+1, calls)
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := withRetry(ctx, Config{MaxRetries: 3, RetryDelay: time.Hour}, "test", func(context.Context) error {
+			calls++
+			cancel()
+			return slowDownErr()
+		})
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retryDelay(0, 3))
+
+	for attempt := range 40 {
+		delay := retryDelay(100*time.Millisecond, attempt)
+		assert.Positive(t, delay)
+		assert.LessOrEqual(t, delay, maxRetryDelay)
+		if attempt == 0 {
+			assert.LessOrEqual(t, delay, 100*time.Millisecond)
+		}
+	}
+}
+
+func TestS3BackendListRetriesThrottling(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			IndexFile:  "index.html",
+			DateFormat: "2006-01-02",
+			MaxRetries: 2,
+			RetryDelay: time.Microsecond,
+		},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).
+		Return((*s3.ListObjectsV2Output)(nil), slowDownErr()).Once()
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("file1.txt"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+	}, nil).Once()
+
+	listing, err := backend.List(context.Background(), "/")
+	require.NoError(t, err)
+	assert.Len(t, listing.Items, 1)
+
+	mockSvc.AssertNumberOfCalls(t, "ListObjectsV2WithContext", 2)
+}
+
+func TestS3BackendListAttempts(t *testing.T) {
+	// An S3 endpoint that is always throttling, counting the requests made to
+	// it by the SDK.
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+	}))
+	defer server.Close()
+
+	sess, err := newS3Session(&aws.Config{
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	require.NoError(t, err)
+
+	backend := S3Backend{
+		svc:    s3.New(sess),
+		bucket: "test-bucket",
+		cfg:    Config{IndexFile: "index.html", MaxRetries: 2, RetryDelay: time.Microsecond},
+	}
+
+	_, err = backend.List(context.Background(), "/")
+	require.Error(t, err)
+	// The first attempt and two retries, without any retries of the SDK
+	assert.Equal(t, int32(3), requests.Load())
+}
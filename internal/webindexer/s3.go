@@ -1,75 +1,192 @@
 package webindexer
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/charmbracelet/log"
 )
 
 type S3Backend struct {
-	svc    S3API
-	bucket string
-	cfg    Config
+	svc       S3API
+	bucket    string
+	cfg       Config
+	checksums *checksumCache
 }
 
 type S3API interface {
-	ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
-	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
+	ListObjectsV2WithContext(
+		ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option,
+	) (*s3.ListObjectsV2Output, error)
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
+	HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error)
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+	DeleteObjectWithContext(
+		ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option,
+	) (*s3.DeleteObjectOutput, error)
 }
 
-var _ FileSource = &S3Backend{}
+var (
+	_ FileSource      = &S3Backend{}
+	_ FileSourceV2    = &S3Backend{}
+	_ ItemIterator    = &S3Backend{}
+	_ FileWriter      = &S3Backend{}
+	_ ContentComparer = &S3Backend{}
+	_ FileReader      = &S3Backend{}
+	_ FileRemover     = &S3Backend{}
+	_ ModTimeChecker  = &S3Backend{}
+)
 
 func (s *S3Backend) Read(prefix string) ([]Item, bool, error) {
-	// Ensure the prefix has a trailing slash for s3 keys
-	if !strings.HasSuffix(prefix, "/") {
-		prefix = prefix + "/"
+	listing, err := s.List(context.Background(), prefix)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// Remove leading slash for s3 keys
-	prefix = strings.TrimPrefix(prefix, "/")
-	if prefix == "/" {
-		prefix = ""
-	}
+	items, hasNoIndex := legacyItems(listing)
 
-	log.Debugf("Listing objects in %s/%s", s.bucket, prefix)
+	return items, hasNoIndex, nil
+}
 
-	req := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(s.bucket),
-		Prefix:    aws.String(prefix),
-		Delimiter: aws.String("/"),
+// List reads the objects and common prefixes under prefix, honouring noindex
+// and skipindex files.
+func (s *S3Backend) List(ctx context.Context, prefix string) (Listing, error) {
+	var noIndexDirs []string
+	notes := make(map[string]*s3.Object)
+	listing, err := collectListing(s.listItems(ctx, prefix, func(name string) {
+		noIndexDirs = append(noIndexDirs, name)
+	}, func(name string, object *s3.Object) {
+		notes[name] = object
+	}))
+	if err != nil {
+		return Listing{}, err
 	}
+	listing.NoIndexDirs = noIndexDirs
+	listing.Metadata = map[string]string{"bucket": s.bucket, "prefix": s3Prefix(prefix)}
 
-	resp, err := s.svc.ListObjectsV2(req)
-	if err != nil {
-		return nil, false, fmt.Errorf("unable to list S3 objects: %w", err)
+	if listing.Marker == MarkerNone && len(notes) > 0 {
+		listing.Notes, err = s.cfg.readNotes(prefix, func(name string) ([]byte, bool, error) {
+			object, ok := notes[name]
+			if !ok {
+				return nil, false, nil
+			}
+			if aws.Int64Value(object.Size) > maxNoteSize {
+				log.Warnf("Not rendering %s/%s as it is larger than %s",
+					s.bucket, aws.StringValue(object.Key), humanizeBytes(maxNoteSize))
+				return nil, false, nil
+			}
+
+			content, err := s.getObject(ctx, aws.StringValue(object.Key))
+
+			return content, err == nil, err
+		})
+		if err != nil {
+			return Listing{}, err
+		}
 	}
 
-	// First check for noindex files or skipindex files before processing anything else
-	for _, content := range resp.Contents {
-		fileName := filepath.Base(*content.Key)
-		// Check for noindex files (skip directory entirely)
-		if len(s.cfg.NoIndexFiles) > 0 && contains(s.cfg.NoIndexFiles, fileName) {
-			log.Infof("Skipping %s/%s (found noindex file %s)", s.bucket, prefix, fileName)
-			return nil, true, nil
+	return listing, nil
+}
+
+// Iterate yields the items under prefix as List lists them. The objects are
+// listed one page at a time, so unless descriptions or companions are
+// enabled, the whole listing isn't held in memory first.
+func (s *S3Backend) Iterate(ctx context.Context, prefix string) iter.Seq2[Item, error] {
+	return s.listItems(ctx, prefix, func(string) {}, func(string, *s3.Object) {})
+}
+
+// listItems yields the items of the listing under prefix, calling omit and
+// note like iterate.
+func (s *S3Backend) listItems(
+	ctx context.Context, prefix string, omit func(name string), note func(name string, object *s3.Object),
+) iter.Seq2[Item, error] {
+	return s.cfg.listItems(prefix, s.iterate(ctx, prefix, omit, note), func(name string) ([]byte, error) {
+		return s.getObject(ctx, s3Prefix(prefix)+name)
+	})
+}
+
+// iterate yields the objects and common prefixes under prefix, calling omit
+// with the name of each common prefix that is left out because it contains a
+// noindex file, and note with each header or readme file found.
+func (s *S3Backend) iterate(
+	ctx context.Context, prefix string, omit func(name string), note func(name string, object *s3.Object),
+) iter.Seq2[Item, error] {
+	prefix = s3Prefix(prefix)
+
+	return func(yield func(Item, error) bool) {
+		log.Debugf("Listing objects in %s/%s", s.bucket, prefix)
+
+		// Check for marker files before listing anything else
+		marker, file, err := s.marker(ctx, prefix)
+		if err != nil {
+			yield(Item{}, err)
+			return
+		}
+		switch marker {
+		case MarkerNoIndex:
+			log.Infof("Skipping %s/%s (found noindex file %s)", s.bucket, prefix, file)
+			yield(Item{}, &MarkerError{Path: prefix, File: file, Marker: marker})
+			return
+		case MarkerSkipIndex:
+			log.Infof("Skipping indexing of %s/%s (found skipindex file %s), will include in parent directory",
+				s.bucket, prefix, file)
+			yield(Item{}, &MarkerError{Path: prefix, File: file, Marker: marker})
+			return
+		case MarkerNone:
+		}
+
+		req := &s3.ListObjectsV2Input{
+			Bucket:    aws.String(s.bucket),
+			Prefix:    aws.String(prefix),
+			Delimiter: aws.String("/"),
 		}
 
-		// Check for skipindex files (skip indexing but include in parent)
-		if len(s.cfg.SkipIndexFiles) > 0 && contains(s.cfg.SkipIndexFiles, fileName) {
-			log.Infof("Skipping indexing of %s/%s (found skipindex file %s), will include in parent directory", s.bucket, prefix, fileName)
-			// Return empty items but mark as not having noindex file
-			// This will prevent indexing this directory but still include it in the parent
-			return []Item{}, false, nil
+		for {
+			resp, err := s.listObjects(ctx, req)
+			if err != nil {
+				yield(Item{}, fmt.Errorf("unable to list S3 objects: %w", err))
+				return
+			}
+
+			for _, content := range resp.Contents {
+				if name := strings.TrimPrefix(*content.Key, prefix); s.cfg.isNoteFile(name) {
+					note(name, content)
+				}
+			}
+
+			if !s.yieldPage(ctx, prefix, resp, omit, yield) {
+				return
+			}
+
+			if !aws.BoolValue(resp.IsTruncated) {
+				return
+			}
+			req.ContinuationToken = resp.NextContinuationToken
 		}
 	}
+}
 
-	var items []Item
-	// Process all other files
+// yieldPage yields the items of a single page of an S3 listing. It returns
+// false if the iteration should stop.
+func (s *S3Backend) yieldPage(
+	ctx context.Context, prefix string, resp *s3.ListObjectsV2Output, omit func(string), yield func(Item, error) bool,
+) bool {
 	for _, content := range resp.Contents {
-		if shouldSkip(*content.Key, s.cfg.IndexFile, s.cfg.Skips) {
+		if s.cfg.shouldSkip(*content.Key) {
 			continue
 		}
 
@@ -81,38 +198,43 @@ func (s *S3Backend) Read(prefix string) ([]Item, bool, error) {
 			Size:         humanizeBytes(*content.Size),
 			LastModified: content.LastModified.Format(s.cfg.DateFormat),
 			IsDir:        false,
+			SizeBytes:    aws.Int64Value(content.Size),
+			ModTime:      aws.TimeValue(content.LastModified),
+			ETag:         strings.Trim(aws.StringValue(content.ETag), `"`),
+			StorageClass: aws.StringValue(content.StorageClass),
 		}
 
-		items = append(items, item)
-	}
-
-	// Only process directories if we haven't found a noindex file
-	for _, commonPrefix := range resp.CommonPrefixes {
-		log.Debugf("Found common prefix: %s", *commonPrefix.Prefix)
+		key := aws.StringValue(content.Key)
+		item.Checksums = s.cfg.checksums(s.checksums, "s3://"+s.bucket+"/"+key, item.SizeBytes, item.ModTime,
+			func() (io.ReadCloser, error) {
+				return s.openObject(ctx, key)
+			})
 
-		// Check if this prefix contains a noindex file
-		subReq := &s3.ListObjectsV2Input{
-			Bucket:    aws.String(s.bucket),
-			Prefix:    commonPrefix.Prefix,
-			Delimiter: aws.String("/"),
+		if s.cfg.sniffs(itemName) {
+			var err error
+			item.MimeType, err = s.headContentType(ctx, key)
+			if err != nil {
+				log.Warnf("Unable to detect the type of %s/%s: %v", s.bucket, key, err)
+			}
 		}
 
-		subResp, err := s.svc.ListObjectsV2(subReq)
-		if err != nil {
-			return nil, false, fmt.Errorf("unable to list S3 objects in prefix %s: %w", *commonPrefix.Prefix, err)
+		if !yield(item, nil) {
+			return false
 		}
+	}
+
+	for _, commonPrefix := range resp.CommonPrefixes {
+		log.Debugf("Found common prefix: %s", *commonPrefix.Prefix)
 
 		// Skip this prefix if it contains a noindex file
-		skipDir := false
-		for _, content := range subResp.Contents {
-			fileName := filepath.Base(*content.Key)
-			if len(s.cfg.NoIndexFiles) > 0 && contains(s.cfg.NoIndexFiles, fileName) {
-				log.Infof("Skipping %s/%s (found noindex file %s)", s.bucket, *commonPrefix.Prefix, fileName)
-				skipDir = true
-				break
-			}
+		noIndexFile, err := s.noIndexFile(ctx, *commonPrefix.Prefix)
+		if err != nil {
+			yield(Item{}, err)
+			return false
 		}
-		if skipDir {
+		if noIndexFile != "" {
+			log.Infof("Skipping %s/%s (found noindex file %s)", s.bucket, *commonPrefix.Prefix, noIndexFile)
+			omit(strings.TrimPrefix(*commonPrefix.Prefix, prefix))
 			continue
 		}
 
@@ -121,14 +243,73 @@ func (s *S3Backend) Read(prefix string) ([]Item, bool, error) {
 			Name:  dirName,
 			IsDir: true,
 		}
-		items = append(items, item)
+		if !yield(item, nil) {
+			return false
+		}
 	}
 
-	return items, false, nil
+	return true
+}
+
+// marker returns the kind and name of the marker file directly under prefix,
+// looking each configured name up rather than listing the whole prefix.
+func (s *S3Backend) marker(ctx context.Context, prefix string) (Marker, string, error) {
+	for _, name := range s.cfg.NoIndexFiles {
+		found, err := s.objectExists(ctx, prefix+name)
+		if err != nil || found {
+			return MarkerNoIndex, name, err
+		}
+	}
+
+	for _, name := range s.cfg.SkipIndexFiles {
+		found, err := s.objectExists(ctx, prefix+name)
+		if err != nil || found {
+			return MarkerSkipIndex, name, err
+		}
+	}
+
+	return MarkerNone, "", nil
+}
+
+// noIndexFile returns the name of the noindex file directly under prefix, or
+// an empty string if there is none.
+func (s *S3Backend) noIndexFile(ctx context.Context, prefix string) (string, error) {
+	for _, name := range s.cfg.NoIndexFiles {
+		found, err := s.objectExists(ctx, prefix+name)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// objectExists reports whether an object with exactly the given key exists.
+// It lists at most one key from the key on, which needs no more than the list
+// permission the rest of the indexing does.
+func (s *S3Backend) objectExists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.listObjects(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to look up S3 object %s: %w", key, err)
+	}
+
+	return len(resp.Contents) > 0 && aws.StringValue(resp.Contents[0].Key) == key, nil
 }
 
 // EnsureDirExists is a no-op for S3 as directories are implicit.
 func (s *S3Backend) EnsureDirExists(relativePath string) error {
+	return s.EnsureDirExistsContext(context.Background(), relativePath)
+}
+
+// EnsureDirExistsContext is a no-op for S3 as directories are implicit.
+func (s *S3Backend) EnsureDirExistsContext(_ context.Context, relativePath string) error {
 	log.Debugf("EnsureDirExists called for S3 (no-op): %s/%s", s.bucket, relativePath)
 	// S3 directories are created implicitly by object keys.
 	// We could potentially check if the bucket exists here if needed.
@@ -136,22 +317,258 @@ func (s *S3Backend) EnsureDirExists(relativePath string) error {
 }
 
 func (s *S3Backend) Write(data Data, content string) error {
+	return s.WriteContext(context.Background(), data, content)
+}
+
+func (s *S3Backend) WriteContext(ctx context.Context, data Data, content string) error {
+	return s.WriteFile(ctx, data.RelativePath, s.cfg.IndexFile, []byte(content), contentTypeHTML)
+}
+
+// WriteFile uploads a file to the target prefix for dir.
+func (s *S3Backend) WriteFile(ctx context.Context, dir, name string, content []byte, contentType string) error {
+	bucket, target := s.objectKey(dir, name)
+
+	size := humanizeBytes(int64(len(content)))
+	log.Infof("Uploading %s to %s/%s", size, bucket, target)
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(target),
+		ContentType: aws.String(contentType),
+	}
+	// HTML indexes have always been uploaded with this encoding, so keep it
+	// for them rather than changing the metadata of existing objects.
+	if contentType == contentTypeHTML {
+		input.ContentEncoding = aws.String("utf-8")
+	}
+
+	op := fmt.Sprintf("upload of s3://%s/%s", bucket, target)
+
+	return withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		reqCtx, cancel := s.requestContext(ctx)
+		defer cancel()
+
+		// A new reader for each attempt, as a failed attempt may have
+		// consumed the previous one.
+		input.Body = aws.ReadSeekCloser(bytes.NewReader(content))
+		_, err := s.svc.PutObjectWithContext(reqCtx, input)
+
+		return err
+	})
+}
+
+// Unchanged reports whether the object for a file already exists with the
+// given content, by comparing its ETag to the content's MD5 checksum.
+func (s *S3Backend) Unchanged(ctx context.Context, dir, name string, content []byte) (bool, error) {
+	bucket, target := s.objectKey(dir, name)
+
+	resp, err := s.headObject(ctx, bucket, target)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// #nosec G401 -- S3 ETags are MD5 checksums of the object
+	sum := md5.Sum(content)
+
+	return strings.Trim(aws.StringValue(resp.ETag), `"`) == hex.EncodeToString(sum[:]), nil
+}
+
+// ModTime returns the last modification time of a file in the target prefix
+// for dir.
+func (s *S3Backend) ModTime(ctx context.Context, dir, name string) (time.Time, bool, error) {
+	bucket, target := s.objectKey(dir, name)
+
+	resp, err := s.headObject(ctx, bucket, target)
+	if isNotFound(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return aws.TimeValue(resp.LastModified), true, nil
+}
+
+// RemoveFile deletes a file from the target prefix for dir, if it exists.
+func (s *S3Backend) RemoveFile(ctx context.Context, dir, name string) (bool, error) {
+	bucket, target := s.objectKey(dir, name)
+
+	// Deleting a missing object succeeds, so check that it exists first.
+	_, err := s.headObject(ctx, bucket, target)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	op := fmt.Sprintf("deletion of s3://%s/%s", bucket, target)
+	err = withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		reqCtx, cancel := s.requestContext(ctx)
+		defer cancel()
+
+		_, err := s.svc.DeleteObjectWithContext(reqCtx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(target),
+		})
+
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Removed %s/%s", bucket, target)
+
+	return true, nil
+}
+
+// headContentType returns the Content-Type of the object with the given key
+// from the source bucket, without its parameters.
+func (s *S3Backend) headContentType(ctx context.Context, key string) (string, error) {
+	resp, err := s.headObject(ctx, s.bucket, key)
+	if err != nil {
+		return "", err
+	}
+
+	return baseType(aws.StringValue(resp.ContentType)), nil
+}
+
+// headObject gets the metadata of an object, retrying transient failures.
+func (s *S3Backend) headObject(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+	var resp *s3.HeadObjectOutput
+	op := fmt.Sprintf("head of s3://%s/%s", bucket, key)
+	err := withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		reqCtx, cancel := s.requestContext(ctx)
+		defer cancel()
+
+		var err error
+		resp, err = s.svc.HeadObjectWithContext(reqCtx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+
+		return err
+	})
+
+	return resp, err
+}
+
+// isNotFound reports whether err is an S3 error for a missing object.
+func isNotFound(err error) bool {
+	var reqErr awserr.RequestFailure
+
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound
+}
+
+// getObject downloads the object with the given key from the source bucket,
+// retrying transient failures.
+func (s *S3Backend) getObject(ctx context.Context, key string) ([]byte, error) {
+	var content []byte
+	op := fmt.Sprintf("download of s3://%s/%s", s.bucket, key)
+
+	err := withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		reqCtx, cancel := s.requestContext(ctx)
+		defer cancel()
+
+		resp, err := s.svc.GetObjectWithContext(reqCtx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		content, err = io.ReadAll(resp.Body)
+
+		return err
+	})
+
+	return content, err
+}
+
+// Open opens the object for the file name under the prefix path for reading.
+func (s *S3Backend) Open(ctx context.Context, path, name string) (io.ReadCloser, error) {
+	return s.openObject(ctx, s3Prefix(path)+name)
+}
+
+// openObject opens the object with the given key from the source bucket for
+// streaming, retrying transient failures to start the download. Unlike
+// getObject, the request timeout doesn't apply to reading the body.
+func (s *S3Backend) openObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	op := fmt.Sprintf("download of s3://%s/%s", s.bucket, key)
+
+	err := withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		resp, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		body = resp.Body
+
+		return nil
+	})
+
+	return body, err
+}
+
+// objectKey returns the bucket and key that a file for the relative
+// directory dir is written to.
+func (s *S3Backend) objectKey(dir, name string) (string, string) {
 	bucket, target := uriToBucketAndPrefix(s.cfg.Target)
 	target = strings.TrimPrefix(target, s.cfg.BasePath)
-	target = filepath.Join(target, data.RelativePath, s.cfg.IndexFile)
+	target = filepath.Join(target, dir, name)
 
-	strReader := strings.NewReader(content)
-	size := humanizeBytes(int64(strReader.Len()))
-	log.Infof("Uploading %s to %s/%s", size, bucket, target)
+	return bucket, target
+}
+
+// listObjects lists a single page of objects, retrying transient failures.
+func (s *S3Backend) listObjects(ctx context.Context, req *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	var resp *s3.ListObjectsV2Output
+	op := fmt.Sprintf("listing of s3://%s/%s", s.bucket, aws.StringValue(req.Prefix))
 
-	_, err := s.svc.PutObject(&s3.PutObjectInput{
-		Bucket:          aws.String(bucket),
-		Key:             aws.String(target),
-		Body:            aws.ReadSeekCloser(strReader),
-		ContentType:     aws.String("text/html"),
-		ContentEncoding: aws.String("utf-8"),
+	err := withRetry(ctx, s.cfg, op, func(ctx context.Context) error {
+		reqCtx, cancel := s.requestContext(ctx)
+		defer cancel()
+
+		var err error
+		resp, err = s.svc.ListObjectsV2WithContext(reqCtx, req)
+
+		return err
 	})
-	return err
+
+	return resp, err
+}
+
+func (s *S3Backend) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cfg.RequestTimeout > 0 {
+		return context.WithTimeout(ctx, s.cfg.RequestTimeout)
+	}
+
+	return ctx, func() {}
+}
+
+// s3Prefix normalizes a path into an S3 key prefix with a trailing slash and
+// no leading slash. The root of a bucket is an empty prefix.
+func s3Prefix(prefix string) string {
+	// Ensure the prefix has a trailing slash for s3 keys
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// Remove leading slash for s3 keys
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	return prefix
 }
 
 func isS3URI(uri string) bool {
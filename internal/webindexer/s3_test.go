@@ -1,11 +1,14 @@
 package webindexer
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,16 +19,41 @@ type MockS3Client struct {
 	mock.Mock
 }
 
-func (m *MockS3Client) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+func (m *MockS3Client) ListObjectsV2WithContext(
+	_ aws.Context, input *s3.ListObjectsV2Input, _ ...request.Option,
+) (*s3.ListObjectsV2Output, error) {
 	args := m.Called(input)
 	return args.Get(0).(*s3.ListObjectsV2Output), args.Error(1)
 }
 
-func (m *MockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+func (m *MockS3Client) PutObjectWithContext(
+	_ aws.Context, input *s3.PutObjectInput, _ ...request.Option,
+) (*s3.PutObjectOutput, error) {
 	args := m.Called(input)
 	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
 }
 
+func (m *MockS3Client) HeadObjectWithContext(
+	_ aws.Context, input *s3.HeadObjectInput, _ ...request.Option,
+) (*s3.HeadObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
+}
+
+func (m *MockS3Client) GetObjectWithContext(
+	_ aws.Context, input *s3.GetObjectInput, _ ...request.Option,
+) (*s3.GetObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+}
+
+func (m *MockS3Client) DeleteObjectWithContext(
+	_ aws.Context, input *s3.DeleteObjectInput, _ ...request.Option,
+) (*s3.DeleteObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.DeleteObjectOutput), args.Error(1)
+}
+
 func TestS3BackendRead(t *testing.T) {
 	// Arrange the test
 	mockSvc := new(MockS3Client)
@@ -39,7 +67,7 @@ func TestS3BackendRead(t *testing.T) {
 		},
 	}
 
-	mockSvc.On("ListObjectsV2", mock.Anything).Return(&s3.ListObjectsV2Output{
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
 		Contents: []*s3.Object{
 			{
 				Key:          aws.String("prefix/file1.txt"),
@@ -126,8 +154,8 @@ func TestS3BackendReadWithNoIndex(t *testing.T) {
 	}
 
 	// Mock response with a noindex file
-	mockSvc.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
-		return *input.Bucket == "test-bucket" && *input.Prefix == "prefix/"
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Bucket == "test-bucket" && *input.Prefix == "prefix/.noindex"
 	})).Return(&s3.ListObjectsV2Output{
 		Contents: []*s3.Object{
 			{
@@ -165,8 +193,8 @@ func TestS3BackendReadWithNoIndexSimple(t *testing.T) {
 	}
 
 	// Mock response with a noindex file
-	mockSvc.On("ListObjectsV2", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
-		return *input.Bucket == "test-bucket" && (*input.Prefix == "" || *input.Prefix == "/")
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Bucket == "test-bucket" && *input.Prefix == ".noindex"
 	})).Return(&s3.ListObjectsV2Output{
 		Contents: []*s3.Object{
 			{
@@ -199,7 +227,7 @@ func TestS3BackendWrite(t *testing.T) {
 	}
 
 	// Setup mock response for PutObject
-	mockSvc.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Return(&s3.PutObjectOutput{}, nil)
+	mockSvc.On("PutObjectWithContext", mock.AnythingOfType("*s3.PutObjectInput")).Return(&s3.PutObjectOutput{}, nil)
 
 	data := Data{
 		RelativePath: "subdir/",
@@ -211,7 +239,7 @@ func TestS3BackendWrite(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify that PutObject was called as expected
-	mockSvc.AssertCalled(t, "PutObject", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+	mockSvc.AssertCalled(t, "PutObjectWithContext", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
 		return *input.Bucket == "test-bucket" &&
 			strings.HasSuffix(*input.Key, "subdir/index.html") &&
 			*input.ContentType == "text/html" &&
@@ -221,6 +249,26 @@ func TestS3BackendWrite(t *testing.T) {
 	mockSvc.AssertExpectations(t)
 }
 
+func TestS3BackendWriteFile(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	s3Backend := S3Backend{
+		svc: mockSvc,
+		cfg: Config{Target: "s3://test-bucket/prefix/"},
+	}
+
+	mockSvc.On("PutObjectWithContext", mock.AnythingOfType("*s3.PutObjectInput")).Return(&s3.PutObjectOutput{}, nil)
+
+	err := s3Backend.WriteFile(context.Background(), "subdir/", "index.json", []byte("{}"), "application/json")
+	require.NoError(t, err)
+
+	mockSvc.AssertCalled(t, "PutObjectWithContext", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Bucket == "test-bucket" &&
+			*input.Key == "prefix/subdir/index.json" &&
+			*input.ContentType == "application/json" &&
+			input.ContentEncoding == nil
+	}))
+}
+
 func TestIsS3URI(t *testing.T) {
 	assert.True(t, isS3URI("s3://test-bucket/"))
 	assert.True(t, isS3URI("s3://test-bucket"))
@@ -242,3 +290,241 @@ func TestS3URIToBucketAndPrefix(t *testing.T) {
 	assert.Equal(t, "test-bucket", bucket)
 	assert.Equal(t, "one/two/three", prefix)
 }
+
+func TestS3BackendListPaginated(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			IndexFile:      "index.html",
+			DateFormat:     "2006-01-02",
+			SkipIndexFiles: []string{".skipindex"},
+		},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/.skipindex"
+	})).Return(&s3.ListObjectsV2Output{}, nil).Once()
+
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/" && input.ContinuationToken == nil
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("prefix/file1.txt"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String("page2"),
+	}, nil).Once()
+
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/" && aws.StringValue(input.ContinuationToken) == "page2"
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("prefix/file2.txt"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+	}, nil).Once()
+
+	listing, err := backend.List(context.Background(), "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, MarkerNone, listing.Marker)
+	require.Len(t, listing.Items, 2)
+	assert.Equal(t, "file1.txt", listing.Items[0].Name)
+	assert.Equal(t, "file2.txt", listing.Items[1].Name)
+	assert.Equal(t, "test-bucket", listing.Metadata["bucket"])
+	assert.Equal(t, "prefix/", listing.Metadata["prefix"])
+
+	mockSvc.AssertExpectations(t)
+}
+
+func TestS3BackendListWithSkipIndex(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			IndexFile:      "index.html",
+			SkipIndexFiles: []string{".skipindex"},
+		},
+	}
+
+	mockSvc.On("ListObjectsV2WithContext", mock.Anything).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{
+			{Key: aws.String("prefix/.skipindex"), Size: aws.Int64(0), LastModified: aws.Time(time.Now())},
+			{Key: aws.String("prefix/file1.txt"), Size: aws.Int64(1), LastModified: aws.Time(time.Now())},
+		},
+	}, nil)
+
+	listing, err := backend.List(context.Background(), "prefix/")
+	require.NoError(t, err)
+	assert.Equal(t, MarkerSkipIndex, listing.Marker)
+	assert.Equal(t, ".skipindex", listing.MarkerFile)
+	assert.Empty(t, listing.Items)
+}
+
+func TestS3BackendListMarkerLookup(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			IndexFile:      "index.html",
+			NoIndexFiles:   []string{".noindex"},
+			SkipIndexFiles: []string{".skipindex"},
+			Checksums:      []string{"sha256"},
+		},
+	}
+
+	// Only the markers are looked up; a key that merely starts with the name
+	// of one isn't a marker
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/.noindex" && aws.Int64Value(input.MaxKeys) == 1
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{{Key: aws.String("prefix/.noindex-notes.txt")}},
+	}, nil).Once()
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/.skipindex" && aws.Int64Value(input.MaxKeys) == 1
+	})).Return(&s3.ListObjectsV2Output{
+		Contents: []*s3.Object{{Key: aws.String("prefix/.skipindex")}},
+	}, nil).Once()
+
+	listing, err := backend.List(context.Background(), "prefix/")
+	require.NoError(t, err)
+	assert.Equal(t, MarkerSkipIndex, listing.Marker)
+	assert.Equal(t, ".skipindex", listing.MarkerFile)
+	mockSvc.AssertExpectations(t)
+	mockSvc.AssertNotCalled(t, "GetObjectWithContext", mock.Anything)
+}
+
+func TestS3BackendListOmitsNoIndexPrefixes(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	backend := S3Backend{
+		svc:    mockSvc,
+		bucket: "test-bucket",
+		cfg: Config{
+			IndexFile:    "index.html",
+			NoIndexFiles: []string{".noindex"},
+		},
+	}
+
+	for prefix, found := range map[string]bool{"prefix/": false, "prefix/a/": true, "prefix/b/": false} {
+		output := &s3.ListObjectsV2Output{}
+		if found {
+			output.Contents = []*s3.Object{{Key: aws.String(prefix + ".noindex")}}
+		}
+		mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+			return *input.Prefix == prefix+".noindex" && aws.Int64Value(input.MaxKeys) == 1
+		})).Return(output, nil).Once()
+	}
+	mockSvc.On("ListObjectsV2WithContext", mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return *input.Prefix == "prefix/"
+	})).Return(&s3.ListObjectsV2Output{
+		CommonPrefixes: []*s3.CommonPrefix{{Prefix: aws.String("prefix/a/")}, {Prefix: aws.String("prefix/b/")}},
+	}, nil).Once()
+
+	listing, err := backend.List(context.Background(), "prefix/")
+	require.NoError(t, err)
+	require.Len(t, listing.Items, 1)
+	assert.Equal(t, "b/", listing.Items[0].Name)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestS3Prefix(t *testing.T) {
+	assert.Equal(t, "", s3Prefix(""))
+	assert.Equal(t, "", s3Prefix("/"))
+	assert.Equal(t, "prefix/", s3Prefix("prefix"))
+	assert.Equal(t, "prefix/", s3Prefix("/prefix/"))
+}
+
+func TestS3BackendUnchanged(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	s3Backend := S3Backend{
+		svc: mockSvc,
+		cfg: Config{
+			Target:    "s3://test-bucket/",
+			IndexFile: "index.html",
+		},
+	}
+
+	content := []byte("<html>Test Content</html>")
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "same/index.html"
+	})).Return(&s3.HeadObjectOutput{
+		// MD5 of content
+		ETag: aws.String(`"0825b3e280151e470fafe58420ecca13"`),
+	}, nil)
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "missing/index.html"
+	})).Return((*s3.HeadObjectOutput)(nil), awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, ""))
+
+	unchanged, err := s3Backend.Unchanged(context.Background(), "same/", "index.html", content)
+	require.NoError(t, err)
+	assert.True(t, unchanged)
+
+	unchanged, err = s3Backend.Unchanged(context.Background(), "same/", "index.html", []byte("changed"))
+	require.NoError(t, err)
+	assert.False(t, unchanged)
+
+	unchanged, err = s3Backend.Unchanged(context.Background(), "missing/", "index.html", content)
+	require.NoError(t, err)
+	assert.False(t, unchanged)
+}
+
+func TestS3BackendRemoveFile(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	s3Backend := S3Backend{
+		svc: mockSvc,
+		cfg: Config{
+			Target:    "s3://test-bucket/",
+			IndexFile: "index.html",
+		},
+	}
+
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "dir/index-3.html"
+	})).Return(&s3.HeadObjectOutput{}, nil)
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "dir/index-4.html"
+	})).Return((*s3.HeadObjectOutput)(nil), awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, ""))
+	mockSvc.On("DeleteObjectWithContext", mock.MatchedBy(func(input *s3.DeleteObjectInput) bool {
+		return *input.Bucket == "test-bucket" && *input.Key == "dir/index-3.html"
+	})).Return(&s3.DeleteObjectOutput{}, nil)
+
+	removed, err := s3Backend.RemoveFile(context.Background(), "dir/", "index-3.html")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	// A missing object isn't deleted
+	removed, err = s3Backend.RemoveFile(context.Background(), "dir/", "index-4.html")
+	require.NoError(t, err)
+	assert.False(t, removed)
+	mockSvc.AssertNumberOfCalls(t, "DeleteObjectWithContext", 1)
+}
+
+func TestS3BackendModTime(t *testing.T) {
+	mockSvc := new(MockS3Client)
+	s3Backend := S3Backend{
+		svc: mockSvc,
+		cfg: Config{
+			Target:    "s3://test-bucket/",
+			IndexFile: "index.html",
+		},
+	}
+
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "dir/.thumbnails/shot.png.png"
+	})).Return(&s3.HeadObjectOutput{LastModified: aws.Time(lastModified)}, nil)
+	mockSvc.On("HeadObjectWithContext", mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == "dir/.thumbnails/other.png.png"
+	})).Return((*s3.HeadObjectOutput)(nil), awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, ""))
+
+	modTime, exists, err := s3Backend.ModTime(context.Background(), "dir/", ".thumbnails/shot.png.png")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, lastModified, modTime)
+
+	_, exists, err = s3Backend.ModTime(context.Background(), "dir/", ".thumbnails/other.png.png")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
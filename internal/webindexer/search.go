@@ -0,0 +1,84 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// searchIndexFile is the name of the search index written to the root of the
+// target.
+const searchIndexFile = "search.json"
+
+// searchIndex is the structure of the search index. Paths are relative to
+// the root of the tree, with a trailing slash for directories, which link to
+// Index when it is set.
+type searchIndex struct {
+	Index string   `json:"index"`
+	Paths []string `json:"paths"`
+}
+
+// rootURL returns the URL of the root of the tree as linked from the page
+// for data. It is absolute if a base URL is configured, otherwise relative
+// to the page.
+func (i Indexer) rootURL(r *run, data Data) string {
+	if i.Cfg.BaseURL != "" {
+		rootURL, err := joinURL(i.Cfg.BaseURL, r.root)
+		if err != nil {
+			log.Error("Error joining URL:", err)
+		}
+
+		return strings.TrimSuffix(rootURL, "/") + "/"
+	}
+
+	rel := strings.Trim(strings.TrimPrefix(data.RelativePath, r.root), "/")
+	if rel == "" {
+		return "./"
+	}
+
+	return strings.Repeat("../", strings.Count(rel, "/")+1)
+}
+
+// collectSearch adds the items of a directory to the search index.
+func (i Indexer) collectSearch(r *run, data Data) {
+	if !i.Cfg.Search {
+		return
+	}
+
+	dir := strings.Trim(strings.TrimPrefix(data.RelativePath, r.root), "/")
+	for _, item := range data.Items {
+		p := path.Join(dir, strings.TrimSuffix(item.Name, "/"))
+		if item.IsDir {
+			p += "/"
+		}
+		r.search = append(r.search, p)
+	}
+}
+
+// writeSearchIndex writes the search index to the root directory.
+func (i Indexer) writeSearchIndex(ctx context.Context, r *run, root Data) error {
+	if !i.Cfg.Search {
+		return nil
+	}
+
+	index := searchIndex{Paths: r.search}
+	if index.Paths == nil {
+		index.Paths = []string{}
+	}
+	if i.Cfg.LinkToIndexes {
+		index.Index = i.Cfg.IndexFile
+	}
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("unable to encode search index: %w", err)
+	}
+
+	o := output{kind: outputFile, file: searchIndexFile, contentType: "application/json"}
+
+	return i.writeOutput(ctx, r, o, root, content)
+}
@@ -0,0 +1,71 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootURL(t *testing.T) {
+	indexer := Indexer{}
+	r := &run{root: "/"}
+
+	assert.Equal(t, "./", indexer.rootURL(r, Data{RelativePath: "/"}))
+	assert.Equal(t, "../", indexer.rootURL(r, Data{RelativePath: "/sub"}))
+	assert.Equal(t, "../../", indexer.rootURL(r, Data{RelativePath: "/sub/deeper/"}))
+
+	r.root = "/sub"
+	assert.Equal(t, "../", indexer.rootURL(r, Data{RelativePath: "/sub/deeper"}))
+
+	indexer.Cfg.BaseURL = "https://example.com/files"
+	assert.Equal(t, "https://example.com/files/sub/", indexer.rootURL(r, Data{RelativePath: "/sub/deeper"}))
+}
+
+func TestGenerate_Search(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, name := range []string{"file.txt", "sub/nested.txt"} {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+	}
+
+	cfg := Config{
+		Source:        sourceDir,
+		Target:        targetDir,
+		Recursive:     true,
+		SortBy:        "name",
+		Order:         "asc",
+		IndexFile:     "index.html",
+		BasePath:      sourceDir,
+		DateFormat:    "2006-01-02",
+		LinkToIndexes: true,
+		Search:        true,
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "search.json"))
+	require.NoError(t, err)
+
+	var index searchIndex
+	require.NoError(t, json.Unmarshal(content, &index))
+	assert.Equal(t, "index.html", index.Index)
+	assert.ElementsMatch(t, []string{"file.txt", "sub/", "sub/nested.txt"}, index.Paths)
+
+	content, err = os.ReadFile(filepath.Join(targetDir, "sub", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `id="search"`)
+	assert.Contains(t, string(content), `fetch("../search.json")`)
+}
@@ -0,0 +1,207 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// sitemapFile is the name of the sitemap, or of the sitemap index when
+	// the URLs are split across several sitemaps.
+	sitemapFile = "sitemap.xml"
+	// sitemapMaxURLs is the maximum number of URLs in a single sitemap, as
+	// set by the sitemap protocol.
+	sitemapMaxURLs = 50000
+)
+
+// sitemapPartPattern matches the sitemaps listed in a sitemap index.
+var sitemapPartPattern = regexp.MustCompile(`^sitemap-\d+\.xml$`)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// isSitemapFile reports whether name is one of the generated sitemap files.
+func isSitemapFile(name string) bool {
+	return name == sitemapFile || sitemapPartPattern.MatchString(name)
+}
+
+// collectSitemap adds each page of the HTML index of a directory, and
+// optionally its files, to the sitemap.
+func (i Indexer) collectSitemap(r *run, data Data) {
+	if !i.Cfg.Sitemap {
+		return
+	}
+
+	if o, ok := i.Cfg.htmlOutput(); ok {
+		for _, p := range i.pages(o, data) {
+			page, err := i.indexPageURL(data, p.output.file)
+			if err != nil {
+				log.Errorf("Unable to build sitemap URL for %s: %v", data.RelativePath, err)
+				break
+			}
+
+			var newest time.Time
+			for _, item := range p.data.Items {
+				if item.ModTime.After(newest) {
+					newest = item.ModTime
+				}
+			}
+			r.sitemap = append(r.sitemap, sitemapURL{Loc: page, LastMod: sitemapTime(newest)})
+		}
+	}
+
+	i.collectSitemapFiles(r, data)
+}
+
+// collectSitemapFiles adds the files of a directory to the sitemap, if
+// configured.
+func (i Indexer) collectSitemapFiles(r *run, data Data) {
+	if !i.Cfg.Sitemap || !i.Cfg.SitemapFiles {
+		return
+	}
+
+	for _, item := range data.Items {
+		if !item.IsDir {
+			r.sitemap = append(r.sitemap, sitemapURL{Loc: item.URL, LastMod: sitemapTime(item.ModTime)})
+		}
+	}
+}
+
+// htmlOutput returns the HTML index output, if HTML indexes are generated.
+func (c Config) htmlOutput() (output, bool) {
+	for _, o := range c.outputs() {
+		if o.format == FormatHTML {
+			return o, true
+		}
+	}
+
+	return output{}, false
+}
+
+// indexPageURL returns the absolute URL of an HTML index file of a
+// directory, such as the file of one of its pages.
+func (i Indexer) indexPageURL(data Data, file string) (string, error) {
+	page, err := joinURL(i.Cfg.BaseURL, data.RelativePath)
+	if err != nil {
+		return "", err
+	}
+	page = strings.TrimSuffix(page, "/") + "/"
+	if file != i.Cfg.IndexFile || i.Cfg.LinkToIndexes {
+		page += file
+	}
+
+	return page, nil
+}
+
+// sitemapTime formats t for lastmod, or returns an empty string if it is
+// unknown.
+func sitemapTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeSitemap writes the sitemap to the root directory. Over
+// sitemapMaxURLs, the URLs are split into numbered sitemaps listed by a
+// sitemap index. Numbered sitemaps left over from an earlier, larger run
+// are removed.
+func (i Indexer) writeSitemap(ctx context.Context, r *run, root Data) error {
+	if !i.Cfg.Sitemap {
+		return nil
+	}
+
+	if len(r.sitemap) <= sitemapMaxURLs {
+		if err := i.writeSitemapFile(ctx, r, root, sitemapFile, sitemapURLSet{URLs: r.sitemap}); err != nil {
+			return err
+		}
+
+		return i.removeStaleSitemaps(ctx, root, 0)
+	}
+
+	var index sitemapIndex
+	for part, start := 1, 0; start < len(r.sitemap); part, start = part+1, start+sitemapMaxURLs {
+		urls := r.sitemap[start:min(start+sitemapMaxURLs, len(r.sitemap))]
+		name := sitemapPartFile(part)
+		if err := i.writeSitemapFile(ctx, r, root, name, sitemapURLSet{URLs: urls}); err != nil {
+			return err
+		}
+
+		loc, err := joinURL(i.Cfg.BaseURL, root.RelativePath, name)
+		if err != nil {
+			return fmt.Errorf("unable to build sitemap link for %s: %w", name, err)
+		}
+
+		var newest string
+		for _, u := range urls {
+			// RFC 3339 times in UTC sort lexically
+			newest = max(newest, u.LastMod)
+		}
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: loc, LastMod: newest})
+	}
+
+	if err := i.writeSitemapFile(ctx, r, root, sitemapFile, index); err != nil {
+		return err
+	}
+
+	return i.removeStaleSitemaps(ctx, root, len(index.Sitemaps))
+}
+
+// sitemapPartFile returns the file name of a numbered sitemap.
+func sitemapPartFile(part int) string {
+	return fmt.Sprintf("sitemap-%d.xml", part)
+}
+
+// removeStaleSitemaps removes the numbered sitemaps after the given count,
+// left over from a run with more URLs. Nothing is removed if the target
+// can't remove files.
+func (i Indexer) removeStaleSitemaps(ctx context.Context, root Data, count int) error {
+	remover, ok := i.target().(FileRemover)
+	if !ok {
+		return nil
+	}
+
+	for part := count + 1; ; part++ {
+		file := sitemapPartFile(part)
+		removed, err := remover.RemoveFile(ctx, root.RelativePath, file)
+		if err != nil {
+			return fmt.Errorf("unable to remove stale sitemap %s: %w", file, err)
+		}
+		if !removed {
+			return nil
+		}
+	}
+}
+
+// writeSitemapFile encodes a sitemap or sitemap index and writes it to the
+// root directory.
+func (i Indexer) writeSitemapFile(ctx context.Context, r *run, root Data, name string, sitemap any) error {
+	content, err := xml.MarshalIndent(sitemap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode %s: %w", name, err)
+	}
+	content = append([]byte(xml.Header), append(content, '\n')...)
+
+	o := output{kind: outputFile, file: name, contentType: "application/xml"}
+
+	return i.writeOutput(ctx, r, o, root, content)
+}
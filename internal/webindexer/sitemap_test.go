@@ -0,0 +1,206 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSitemapFile(t *testing.T) {
+	assert.True(t, isSitemapFile("sitemap.xml"))
+	assert.True(t, isSitemapFile("sitemap-12.xml"))
+	assert.False(t, isSitemapFile("sitemap-a.xml"))
+	assert.False(t, isSitemapFile("my-sitemap.xml.gz"))
+}
+
+func TestIndexPageURL(t *testing.T) {
+	indexer := Indexer{Cfg: Config{BaseURL: "https://example.com/files", IndexFile: "index.html"}}
+	data := Data{RelativePath: "/sub"}
+
+	page, err := indexer.indexPageURL(data, "index.html")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/files/sub/", page)
+
+	page, err = indexer.indexPageURL(data, "index-2.html")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/files/sub/index-2.html", page)
+
+	indexer.Cfg.LinkToIndexes = true
+	page, err = indexer.indexPageURL(data, "index.html")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/files/sub/index.html", page)
+}
+
+func TestConfigHTMLOutput(t *testing.T) {
+	o, ok := Config{IndexFile: "index.html"}.htmlOutput()
+	require.True(t, ok)
+	assert.Equal(t, "index.html", o.file)
+
+	_, ok = Config{IndexFile: "index.html", Formats: []string{"json"}}.htmlOutput()
+	assert.False(t, ok)
+}
+
+func TestGenerate_Sitemap(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"file.txt", "sub/nested.txt"} {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+		require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+	}
+
+	cfg := Config{
+		Source:       sourceDir,
+		Target:       targetDir,
+		Recursive:    true,
+		SortBy:       "name",
+		Order:        "asc",
+		IndexFile:    "index.html",
+		BasePath:     sourceDir,
+		BaseURL:      "https://example.com/files",
+		DateFormat:   "2006-01-02",
+		Sitemap:      true,
+		SitemapFiles: true,
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "sitemap.xml"))
+	require.NoError(t, err)
+
+	var urlSet sitemapURLSet
+	require.NoError(t, xml.Unmarshal(content, &urlSet))
+
+	locs := make(map[string]string)
+	for _, u := range urlSet.URLs {
+		locs[u.Loc] = u.LastMod
+	}
+	assert.Len(t, locs, 4)
+	assert.Contains(t, locs, "https://example.com/files/")
+	assert.Contains(t, locs, "https://example.com/files/sub/")
+	assert.Equal(t, "2024-05-01T12:00:00Z", locs["https://example.com/files/file.txt"])
+	assert.Equal(t, "2024-05-01T12:00:00Z", locs["https://example.com/files/sub/nested.txt"])
+}
+
+func TestGenerate_SitemapHidesRootFilesOnly(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, name := range []string{"sitemap.xml", "file.txt", "sub/sitemap.xml", "sub/sitemap-1.xml"} {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+	}
+
+	cfg := Config{
+		Source:       sourceDir,
+		Target:       targetDir,
+		Recursive:    true,
+		SortBy:       "name",
+		Order:        "asc",
+		IndexFile:    "index.html",
+		BasePath:     sourceDir,
+		BaseURL:      "https://example.com/files",
+		DateFormat:   "2006-01-02",
+		Sitemap:      true,
+		SitemapFiles: true,
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "sitemap.xml"))
+	require.NoError(t, err)
+
+	var urlSet sitemapURLSet
+	require.NoError(t, xml.Unmarshal(content, &urlSet))
+
+	var locs []string
+	for _, u := range urlSet.URLs {
+		locs = append(locs, u.Loc)
+	}
+	assert.NotContains(t, locs, "https://example.com/files/sitemap.xml")
+	assert.Contains(t, locs, "https://example.com/files/sub/sitemap.xml")
+	assert.Contains(t, locs, "https://example.com/files/sub/sitemap-1.xml")
+}
+
+func TestWriteSitemap_Index(t *testing.T) {
+	targetDir := t.TempDir()
+	cfg := Config{Target: targetDir, BaseURL: "https://example.com", Sitemap: true}
+	indexer := Indexer{Cfg: cfg, Target: &LocalBackend{path: targetDir, cfg: cfg}}
+
+	r := &run{result: &Result{}}
+	for n := range sitemapMaxURLs + 1 {
+		r.sitemap = append(r.sitemap, sitemapURL{
+			Loc:     fmt.Sprintf("https://example.com/%d.txt", n),
+			LastMod: time.Unix(int64(n), 0).UTC().Format(time.RFC3339),
+		})
+	}
+
+	require.NoError(t, indexer.writeSitemap(context.Background(), r, Data{RelativePath: "/"}))
+	assert.Equal(t, 3, r.result.FilesWritten)
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "sitemap.xml"))
+	require.NoError(t, err)
+
+	var index sitemapIndex
+	require.NoError(t, xml.Unmarshal(content, &index))
+	require.Len(t, index.Sitemaps, 2)
+	assert.Equal(t, "https://example.com/sitemap-1.xml", index.Sitemaps[0].Loc)
+	assert.Equal(t, "https://example.com/sitemap-2.xml", index.Sitemaps[1].Loc)
+	assert.Equal(t, time.Unix(sitemapMaxURLs, 0).UTC().Format(time.RFC3339), index.Sitemaps[1].LastMod)
+
+	content, err = os.ReadFile(filepath.Join(targetDir, "sitemap-2.xml"))
+	require.NoError(t, err)
+
+	var urlSet sitemapURLSet
+	require.NoError(t, xml.Unmarshal(content, &urlSet))
+	assert.Len(t, urlSet.URLs, 1)
+}
+
+func TestWriteSitemap_RemovesStaleParts(t *testing.T) {
+	targetDir := t.TempDir()
+	for _, name := range []string{"sitemap-1.xml", "sitemap-2.xml", "sitemap-3.xml", "sitemap-5.xml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(targetDir, name), []byte("stale"), 0o644))
+	}
+
+	cfg := Config{Target: targetDir, BaseURL: "https://example.com", Sitemap: true}
+	indexer := Indexer{Cfg: cfg, Target: &LocalBackend{path: targetDir, cfg: cfg}}
+
+	r := &run{result: &Result{}}
+	for n := range sitemapMaxURLs + 1 {
+		r.sitemap = append(r.sitemap, sitemapURL{Loc: fmt.Sprintf("https://example.com/%d.txt", n)})
+	}
+
+	require.NoError(t, indexer.writeSitemap(context.Background(), r, Data{RelativePath: "/"}))
+	assert.FileExists(t, filepath.Join(targetDir, "sitemap-1.xml"))
+	assert.FileExists(t, filepath.Join(targetDir, "sitemap-2.xml"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "sitemap-3.xml"))
+	// Removal stops at the first missing part, like stale pages
+	assert.FileExists(t, filepath.Join(targetDir, "sitemap-5.xml"))
+
+	r = &run{result: &Result{}, sitemap: r.sitemap[:1]}
+	require.NoError(t, indexer.writeSitemap(context.Background(), r, Data{RelativePath: "/"}))
+	assert.FileExists(t, filepath.Join(targetDir, "sitemap.xml"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "sitemap-1.xml"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "sitemap-2.xml"))
+}
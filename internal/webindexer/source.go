@@ -0,0 +1,250 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+// Marker identifies the kind of marker file found in a directory.
+type Marker int
+
+const (
+	// MarkerNone means the directory doesn't contain a marker file.
+	MarkerNone Marker = iota
+	// MarkerNoIndex means the directory contains a noindex file and should
+	// be skipped entirely, including from its parent's listing.
+	MarkerNoIndex
+	// MarkerSkipIndex means the directory contains a skipindex file and
+	// should not be indexed, but is still listed in its parent.
+	MarkerSkipIndex
+)
+
+func (m Marker) String() string {
+	switch m {
+	case MarkerNoIndex:
+		return "noindex"
+	case MarkerSkipIndex:
+		return "skipindex"
+	default:
+		return "none"
+	}
+}
+
+// Listing is the result of reading a single directory from a source.
+type Listing struct {
+	// Items are the entries of the directory. It is empty when a marker was
+	// found.
+	Items []Item
+	// Marker is the kind of marker file found in the directory, if any.
+	Marker Marker
+	// MarkerFile is the name of the marker file that was found.
+	MarkerFile string
+	// NoIndexDirs are the names of subdirectories left out of Items because
+	// they contain a noindex file.
+	NoIndexDirs []string
+	// Notes are the rendered header and readme files of the directory, if
+	// any are configured and found.
+	Notes Notes
+	// Metadata holds backend specific information about the listing, such as
+	// the bucket and prefix for S3.
+	Metadata map[string]string
+}
+
+// FileSourceV2 is the context-aware successor to FileSource. Sources
+// implementing it can be cancelled and report the kind of marker file found
+// in a directory rather than a single boolean.
+type FileSourceV2 interface {
+	List(ctx context.Context, path string) (Listing, error)
+	WriteContext(ctx context.Context, data Data, content string) error
+	EnsureDirExistsContext(ctx context.Context, relativePath string) error
+}
+
+// ItemIterator is optionally implemented by sources that can iterate over a
+// directory without loading the whole listing into memory at once.
+//
+// If the directory contains a marker file, the iteration ends by yielding a
+// *MarkerError. Backends check for markers before yielding items wherever
+// possible, but a marker discovered late (for example on a later page of an
+// S3 listing) means items already yielded must be discarded.
+type ItemIterator interface {
+	Iterate(ctx context.Context, path string) iter.Seq2[Item, error]
+}
+
+// FileWriter is optionally implemented by targets that can write files other
+// than the HTML index, such as JSON listings. The dir is relative to the
+// target, like Data.RelativePath, and name may include subdirectories.
+type FileWriter interface {
+	WriteFile(ctx context.Context, dir, name string, content []byte, contentType string) error
+}
+
+// FileReader is optionally implemented by sources that can read the content
+// of a file, such as for previews. The path is that of the directory, as
+// passed to List.
+type FileReader interface {
+	Open(ctx context.Context, path, name string) (io.ReadCloser, error)
+}
+
+// FileRemover is optionally implemented by targets that can remove files
+// written by an earlier run, such as the pages of a listing that got shorter.
+// It reports whether the file existed.
+type FileRemover interface {
+	RemoveFile(ctx context.Context, dir, name string) (bool, error)
+}
+
+// ModTimeChecker is optionally implemented by targets that can tell when a
+// file was last written, so that files made from source files that haven't
+// changed since, such as thumbnails, aren't made again. It reports false if
+// the file doesn't exist.
+type ModTimeChecker interface {
+	ModTime(ctx context.Context, dir, name string) (time.Time, bool, error)
+}
+
+// ContentComparer is optionally implemented by targets that can tell whether
+// a file already exists with the given content, so that unchanged files
+// aren't written again.
+type ContentComparer interface {
+	Unchanged(ctx context.Context, dir, name string, content []byte) (bool, error)
+}
+
+// MarkerError is yielded by an ItemIterator when it finds a marker file.
+type MarkerError struct {
+	Path   string
+	File   string
+	Marker Marker
+}
+
+func (e *MarkerError) Error() string {
+	return fmt.Sprintf("found %s file %s in %s", e.Marker, e.File, e.Path)
+}
+
+// collectListing drains an item iterator into a Listing, translating a
+// MarkerError into the listing's marker.
+func collectListing(seq iter.Seq2[Item, error]) (Listing, error) {
+	var listing Listing
+	for item, err := range seq {
+		var markerErr *MarkerError
+		if errors.As(err, &markerErr) {
+			return Listing{Marker: markerErr.Marker, MarkerFile: markerErr.File}, nil
+		}
+		if err != nil {
+			return Listing{}, err
+		}
+		listing.Items = append(listing.Items, item)
+	}
+
+	return listing, nil
+}
+
+// listItems turns the entries of a directory into the items of its listing,
+// as yielded by both List and Iterate. The directories of generated pages and
+// thumbnails are left out. Descriptions and companions depend on the other
+// entries of the directory, so when they are enabled the entries are read in
+// full before any item is yielded.
+func (c Config) listItems(
+	dir string, entries iter.Seq2[Item, error], read func(name string) ([]byte, error),
+) iter.Seq2[Item, error] {
+	whole := c.Descriptions || len(c.Companions) > 0
+
+	return func(yield func(Item, error) bool) {
+		var items []Item
+		for item, err := range entries {
+			if err != nil {
+				yield(Item{}, err)
+				return
+			}
+			if c.isPageDir(item) || c.isThumbnailDir(item) {
+				continue
+			}
+			if !whole {
+				if !yield(item, nil) {
+					return
+				}
+				continue
+			}
+			items = append(items, item)
+		}
+		if !whole {
+			return
+		}
+
+		items, err := c.describe(dir, items, read)
+		if err != nil {
+			yield(Item{}, err)
+			return
+		}
+		items = c.groupCompanions(dir, items, read)
+
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// legacyItems converts a listing into the return values of FileSource.Read.
+func legacyItems(listing Listing) ([]Item, bool) {
+	switch listing.Marker {
+	case MarkerNoIndex:
+		return nil, true
+	case MarkerSkipIndex:
+		return []Item{}, false
+	default:
+		return listing.Items, false
+	}
+}
+
+// AdaptFileSource returns the given source as a FileSourceV2. Sources that
+// only implement FileSource are wrapped so that they honour cancellation
+// between calls.
+func AdaptFileSource(fs FileSource) FileSourceV2 {
+	if v2, ok := fs.(FileSourceV2); ok {
+		return v2
+	}
+
+	return legacySource{fs: fs}
+}
+
+// legacySource adapts a FileSource to the FileSourceV2 interface.
+type legacySource struct {
+	fs FileSource
+}
+
+func (l legacySource) List(ctx context.Context, path string) (Listing, error) {
+	if err := ctx.Err(); err != nil {
+		return Listing{}, err
+	}
+
+	items, hasNoIndex, err := l.fs.Read(path)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	// The original interface can't distinguish a skipindex directory from an
+	// empty one, so only noindex is reported.
+	if hasNoIndex {
+		return Listing{Marker: MarkerNoIndex}, nil
+	}
+
+	return Listing{Items: items}, nil
+}
+
+func (l legacySource) WriteContext(ctx context.Context, data Data, content string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return l.fs.Write(data, content)
+}
+
+func (l legacySource) EnsureDirExistsContext(ctx context.Context, relativePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return l.fs.EnsureDirExists(relativePath)
+}
@@ -0,0 +1,101 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkerString(t *testing.T) {
+	assert.Equal(t, "none", MarkerNone.String())
+	assert.Equal(t, "noindex", MarkerNoIndex.String())
+	assert.Equal(t, "skipindex", MarkerSkipIndex.String())
+}
+
+func TestCollectListing(t *testing.T) {
+	seq := func(items []Item, final error) iter.Seq2[Item, error] {
+		return func(yield func(Item, error) bool) {
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if final != nil {
+				yield(Item{}, final)
+			}
+		}
+	}
+
+	listing, err := collectListing(seq([]Item{{Name: "a"}, {Name: "b"}}, nil))
+	require.NoError(t, err)
+	assert.Len(t, listing.Items, 2)
+	assert.Equal(t, MarkerNone, listing.Marker)
+
+	// Items yielded before a marker are discarded
+	listing, err = collectListing(seq([]Item{{Name: "a"}},
+		&MarkerError{Path: "dir", File: ".skipindex", Marker: MarkerSkipIndex}))
+	require.NoError(t, err)
+	assert.Empty(t, listing.Items)
+	assert.Equal(t, MarkerSkipIndex, listing.Marker)
+	assert.Equal(t, ".skipindex", listing.MarkerFile)
+
+	_, err = collectListing(seq(nil, errors.New("boom")))
+	assert.EqualError(t, err, "boom")
+}
+
+func TestAdaptFileSource(t *testing.T) {
+	// Backends implementing FileSourceV2 are returned as-is
+	local := &LocalBackend{}
+	assert.Same(t, local, AdaptFileSource(local))
+
+	mockSource := new(MockSource)
+	mockSource.On("Read", "noindex").Return([]Item(nil), true, nil)
+	mockSource.On("Read", "dir").Return([]Item{{Name: "file.txt"}}, false, nil)
+
+	adapted := AdaptFileSource(mockSource)
+
+	listing, err := adapted.List(context.Background(), "noindex")
+	require.NoError(t, err)
+	assert.Equal(t, MarkerNoIndex, listing.Marker)
+
+	listing, err = adapted.List(context.Background(), "dir")
+	require.NoError(t, err)
+	assert.Equal(t, MarkerNone, listing.Marker)
+	assert.Len(t, listing.Items, 1)
+
+	// A cancelled context stops the call before reaching the legacy source
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapted.List(ctx, "dir")
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, adapted.WriteContext(ctx, Data{}, ""), context.Canceled)
+	require.ErrorIs(t, adapted.EnsureDirExistsContext(ctx, "/"), context.Canceled)
+
+	mockSource.AssertNumberOfCalls(t, "Read", 2)
+	mockSource.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
+
+// visibleItems returns the items of a listing made of the given entries.
+func visibleItems(cfg Config, entries []Item) []Item {
+	seq := func(yield func(Item, error) bool) {
+		for _, entry := range entries {
+			if !yield(entry, nil) {
+				return
+			}
+		}
+	}
+
+	var items []Item
+	for item, err := range cfg.listItems("", seq, nil) {
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
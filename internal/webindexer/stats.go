@@ -0,0 +1,121 @@
+package webindexer
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+)
+
+// dirStats summarizes the contents of a directory and its subdirectories.
+type dirStats struct {
+	files  int
+	size   int64
+	newest time.Time
+	// unknown is true when the directory couldn't be listed, because it
+	// failed or contains a skipindex file. Its item then shows no stats.
+	unknown bool
+	// indexed is true when the index files of the directory were written.
+	indexed bool
+}
+
+// add adds an item of a directory to the stats. Subdirectories count with
+// the stats already applied to their item, so those without stats add
+// nothing.
+func (s *dirStats) add(item Item) {
+	if item.IsDir {
+		s.files += item.FileCount
+		s.size += item.TotalSize
+		if item.NewestModTime.After(s.newest) {
+			s.newest = item.NewestModTime
+		}
+
+		return
+	}
+
+	s.files++
+	s.size += item.SizeBytes
+	if item.ModTime.After(s.newest) {
+		s.newest = item.ModTime
+	}
+}
+
+// statsOf returns the stats of a directory with the given items.
+func statsOf(items []Item) dirStats {
+	var stats dirStats
+	for _, item := range items {
+		stats.add(item)
+	}
+
+	return stats
+}
+
+// apply sets the stats on the item of a directory, replacing its size and
+// modification time so that they are shown and sorted like those of files.
+func (s dirStats) apply(item *Item, dateFormat string) {
+	if s.unknown {
+		return
+	}
+	item.HasStats = true
+	item.FileCount = s.files
+	item.TotalSize = s.size
+	item.NewestModTime = s.newest
+	item.SizeBytes = s.size
+	item.Size = humanizeBytes(s.size)
+	if !s.newest.IsZero() {
+		item.ModTime = s.newest
+		item.LastModified = s.newest.Format(dateFormat)
+	}
+}
+
+// walkStats lists a directory and its subdirectories without indexing them,
+// to compute the stats of a directory when not generating recursively. A
+// directory that fails to list is recorded as a failure in keep-going mode
+// and has unknown stats.
+func (i Indexer) walkStats(ctx context.Context, r *run, path string) (dirStats, error) {
+	if err := ctx.Err(); err != nil {
+		return dirStats{}, err
+	}
+
+	listing, err := i.listFrom(ctx, r, i.statsSource(), path)
+	if err != nil {
+		return dirStats{unknown: true}, i.fail(ctx, r, path, err)
+	}
+	if listing.Marker == MarkerSkipIndex {
+		return dirStats{unknown: true}, nil
+	}
+
+	var stats dirStats
+	for _, item := range listing.Items {
+		if item.IsDir {
+			subStats, err := i.walkStats(ctx, r, filepath.Join(path, item.Name))
+			if err != nil {
+				return dirStats{}, err
+			}
+			subStats.apply(&item, i.Cfg.DateFormat)
+		}
+		stats.add(item)
+	}
+
+	return stats, nil
+}
+
+// statsSource returns the source to walk for directory stats. The built-in
+// backends list without checksums, content sniffing, notes, descriptions or
+// companion verification, which the stats don't need. Other sources list as
+// usual.
+func (i Indexer) statsSource() FileSourceV2 {
+	switch s := i.Source.(type) {
+	case *LocalBackend:
+		stats := *s
+		stats.cfg.statsOnly = true
+
+		return &stats
+	case *S3Backend:
+		stats := *s
+		stats.cfg.statsOnly = true
+
+		return &stats
+	}
+
+	return i.source()
+}
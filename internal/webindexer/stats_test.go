@@ -0,0 +1,225 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsOf(t *testing.T) {
+	older := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	stats := statsOf([]Item{
+		{Name: "a.txt", SizeBytes: 10, ModTime: older},
+		{Name: "sub", IsDir: true, FileCount: 2, TotalSize: 100, NewestModTime: newer, ModTime: newer.Add(time.Hour)},
+	})
+	assert.Equal(t, dirStats{files: 3, size: 110, newest: newer}, stats)
+
+	item := Item{Name: "dir", IsDir: true, Size: "4.00 KB", SizeBytes: 4096}
+	stats.apply(&item, "2006-01-02 15:04")
+	assert.True(t, item.HasStats)
+	assert.Equal(t, 3, item.FileCount)
+	assert.Equal(t, int64(110), item.TotalSize)
+	assert.Equal(t, int64(110), item.SizeBytes)
+	assert.Equal(t, humanizeBytes(110), item.Size)
+	assert.Equal(t, "2024-05-01 01:00", item.LastModified)
+	assert.Equal(t, newer, item.ModTime)
+}
+
+func TestGenerate_DirStats(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]time.Time{
+		"top.txt":             base,
+		"sub/a.txt":           base.Add(time.Hour),
+		"sub/deeper/b.txt":    base.Add(3 * time.Hour),
+		"sub/deeper/c.txt":    base.Add(2 * time.Hour),
+		"empty/deeper/.keep":  base,
+		"private/.noindex":    base,
+		"private/ignored.txt": base.Add(10 * time.Hour),
+	}
+
+	for _, recursive := range []bool{true, false} {
+		sourceDir := t.TempDir()
+		targetDir := t.TempDir()
+		for name, modTime := range files {
+			fullPath := filepath.Join(sourceDir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+			require.NoError(t, os.WriteFile(fullPath, []byte("12345"), 0o644))
+			require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+		}
+
+		cfg := Config{
+			Source:       sourceDir,
+			Target:       targetDir,
+			Recursive:    recursive,
+			SortBy:       "name",
+			Order:        "asc",
+			IndexFile:    "index.html",
+			BasePath:     sourceDir,
+			DateFormat:   "2006-01-02 15:04",
+			NoIndexFiles: []string{".noindex"},
+			Skips:        []string{".keep"},
+			Formats:      []string{"html", "json"},
+			DirStats:     true,
+		}
+		indexer := Indexer{
+			Cfg:    cfg,
+			Source: &LocalBackend{path: sourceDir, cfg: cfg},
+			Target: &LocalBackend{path: targetDir, cfg: cfg},
+		}
+
+		_, err := indexer.Generate(context.Background(), sourceDir)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(targetDir, "index.json"))
+		require.NoError(t, err)
+		var listing jsonListing
+		require.NoError(t, json.Unmarshal(content, &listing))
+
+		sizes := make(map[string]int64)
+		for _, item := range listing.Items {
+			require.NotNil(t, item.Size, item.Name)
+			sizes[item.Name] = *item.Size
+		}
+		assert.Equal(t, map[string]int64{"empty": 0, "sub": 15, "top.txt": 5}, sizes)
+
+		content, err = os.ReadFile(filepath.Join(targetDir, "index.html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "(3 files)")
+		assert.Contains(t, string(content), base.Add(3*time.Hour).Local().Format(cfg.DateFormat))
+
+		if recursive {
+			content, err = os.ReadFile(filepath.Join(targetDir, "sub", "index.html"))
+			require.NoError(t, err)
+			assert.Contains(t, string(content), "(2 files)")
+		} else {
+			assert.NoFileExists(t, filepath.Join(targetDir, "sub", "index.html"))
+		}
+	}
+}
+
+func TestStatsSource(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"app.bin":        "\x00\x01binary",
+		"app.bin.sha256": "0000  app.bin\n",
+		"README.md":      "# Readme\n",
+		".descriptions":  "app.bin The app\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, t.TempDir())
+	cfg.Checksums = []string{"sha256"}
+	cfg.Companions = []string{".sha256"}
+	cfg.VerifyCompanions = true
+	cfg.Descriptions = true
+	cfg.ReadmeFiles = []string{"README.md"}
+	cfg.SniffContent = true
+	indexer := Indexer{Cfg: cfg, Source: &LocalBackend{path: sourceDir, cfg: cfg}}
+
+	full, err := indexer.source().List(context.Background(), sourceDir)
+	require.NoError(t, err)
+	stats, err := indexer.statsSource().List(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	// The stats listing has the same items, without the enrichment
+	items := make(map[string]Item)
+	for _, item := range full.Items {
+		items[item.Name] = item
+	}
+	assert.NotEmpty(t, items["app.bin"].Checksums)
+	assert.Equal(t, "The app", items["app.bin"].Description)
+	assert.NotEmpty(t, full.Notes.Readme)
+	assert.Empty(t, stats.Notes.Readme)
+	require.Len(t, stats.Items, len(full.Items))
+	for _, item := range stats.Items {
+		assert.Contains(t, items, item.Name)
+		assert.Empty(t, item.Checksums, item.Name)
+		assert.Empty(t, item.MimeType, item.Name)
+		assert.Empty(t, item.Description, item.Name)
+		assert.False(t, item.Verified, item.Name)
+	}
+	assert.Equal(t, statsOf(full.Items), statsOf(stats.Items))
+
+	// Other sources list as usual
+	indexer.Source = failingSource{LocalBackend: &LocalBackend{path: sourceDir, cfg: cfg}}
+	assert.Equal(t, indexer.source(), indexer.statsSource())
+}
+
+// failingSource is a local source that fails to list one directory.
+type failingSource struct {
+	*LocalBackend
+	fail string
+}
+
+func (f failingSource) List(ctx context.Context, path string) (Listing, error) {
+	if path == f.fail {
+		return Listing{}, errors.New("permission denied")
+	}
+
+	return f.LocalBackend.List(ctx, path)
+}
+
+func TestGenerate_DirStatsUnknown(t *testing.T) {
+	for _, recursive := range []bool{true, false} {
+		for _, tree := range []bool{true, false} {
+			if tree && !recursive {
+				continue
+			}
+
+			sourceDir := t.TempDir()
+			targetDir := t.TempDir()
+			for _, name := range []string{"one/a.txt", "bad/b.txt", "external/.skipindex", "external/c.txt"} {
+				fullPath := filepath.Join(sourceDir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+				require.NoError(t, os.WriteFile(fullPath, []byte("12345"), 0o644))
+			}
+
+			cfg := testConfig(sourceDir, targetDir)
+			cfg.Recursive = recursive
+			cfg.Tree = tree
+			cfg.KeepGoing = true
+			cfg.DirStats = true
+			cfg.Formats = []string{"md"}
+			cfg.SkipIndexFiles = []string{".skipindex"}
+			indexer := Indexer{
+				Cfg:    cfg,
+				Source: failingSource{LocalBackend: &LocalBackend{path: sourceDir, cfg: cfg}, fail: filepath.Join(sourceDir, "bad")},
+				Target: &LocalBackend{path: targetDir, cfg: cfg},
+			}
+
+			_, err := indexer.Generate(context.Background(), sourceDir)
+			var failedErr *FailedPathsError
+			require.ErrorAs(t, err, &failedErr)
+			require.Len(t, failedErr.Failures, 1)
+			assert.Equal(t, filepath.Join(sourceDir, "bad"), failedErr.Failures[0].Path)
+
+			content, err := os.ReadFile(filepath.Join(targetDir, "INDEX.md"))
+			require.NoError(t, err)
+
+			// Only the directory that was listed has stats
+			rows := make(map[string]string)
+			for _, line := range strings.Split(string(content), "\n") {
+				for _, name := range []string{"one", "bad", "external"} {
+					if strings.HasPrefix(line, "| 📁 ["+name+"]") {
+						rows[name] = line
+					}
+				}
+			}
+			assert.Contains(t, rows["one"], "| 5 B (1 file) |")
+			assert.True(t, strings.HasSuffix(rows["bad"], "| - | - |"), rows["bad"])
+			assert.True(t, strings.HasSuffix(rows["external"], "| - | - |"), rows["external"])
+		}
+	}
+}
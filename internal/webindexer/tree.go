@@ -0,0 +1,125 @@
+package webindexer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// generateTree writes a single index for path listing its whole tree, with
+// the contents of each directory in the Items of its item.
+func (i Indexer) generateTree(ctx context.Context, r *run, path string) error {
+	root, err := i.tree(ctx, r, path, "")
+	if err != nil {
+		return i.fail(ctx, r, path, err)
+	}
+
+	data, err := i.data(nil, path)
+	if err != nil {
+		return i.fail(ctx, r, path, err)
+	}
+	data.Items = root.Items
+	data.Header = root.Header
+	data.Readme = root.Readme
+	data.HasChecksums = root.HasChecksums
+	data.Tree = true
+
+	if err := i.writeIndex(ctx, r, path, data); err != nil {
+		return i.fail(ctx, r, path, err)
+	}
+
+	return nil
+}
+
+// tree lists path and all of its subdirectories, returning the data of path
+// with the contents of each directory in its item. Unless a base URL is
+// configured, item URLs are relative to the directory they're in, so they are
+// prefixed with the path of that directory from the root of the tree.
+func (i Indexer) tree(ctx context.Context, r *run, path, prefix string) (Data, error) {
+	if err := ctx.Err(); err != nil {
+		return Data{}, err
+	}
+
+	listing, err := i.list(ctx, r, path)
+	if err != nil {
+		return Data{}, err
+	}
+	if listing.Marker == MarkerNoIndex {
+		return Data{}, nil
+	}
+
+	data, err := i.data(listing.Items, path)
+	if err != nil {
+		return Data{}, err
+	}
+	data.Header = listing.Notes.Header
+	data.Readme = listing.Notes.Readme
+	data.unlisted = listing.Marker == MarkerSkipIndex
+
+	// The root directory is handled by writeIndex like any other index.
+	if prefix != "" {
+		if err := i.writeThumbnails(ctx, r, &data); err != nil {
+			return Data{}, err
+		}
+		i.probeMedia(ctx, &data)
+		if err := i.writeChecksumFiles(ctx, r, data); err != nil {
+			return Data{}, err
+		}
+		if err := i.writeViewerPages(ctx, r, &data); err != nil {
+			return Data{}, err
+		}
+		if err := i.writeDetailPages(ctx, r, data); err != nil {
+			return Data{}, err
+		}
+		i.collectSitemapFiles(r, data)
+		i.collectSearch(r, data)
+		if err := i.collectFeed(ctx, r, data); err != nil {
+			return Data{}, err
+		}
+	}
+
+	for n := range data.Items {
+		item := &data.Items[n]
+		if i.Cfg.BaseURL == "" {
+			item.URL = prefix + item.URL
+			if item.DetailURL != "" {
+				item.DetailURL = prefix + item.DetailURL
+			}
+			if item.ViewerURL != "" {
+				item.ViewerURL = prefix + item.ViewerURL
+			}
+			if item.ThumbnailURL != "" {
+				item.ThumbnailURL = prefix + item.ThumbnailURL
+			}
+			for c := range item.Companions {
+				item.Companions[c].URL = prefix + item.Companions[c].URL
+			}
+		}
+		if !item.IsDir {
+			continue
+		}
+
+		subDirPath := filepath.Join(path, item.Name)
+		sub, err := i.tree(ctx, r, subDirPath, prefix+strings.TrimSuffix(item.Name, "/")+"/")
+		item.Items = sub.Items
+		data.HasChecksums = data.HasChecksums || sub.HasChecksums
+		if err != nil {
+			// A failed subdirectory is listed without its contents in
+			// keep-going mode.
+			if err := i.fail(ctx, r, subDirPath, err); err != nil {
+				return Data{}, err
+			}
+		}
+
+		// A directory that failed or wasn't listed has unknown stats.
+		if i.Cfg.DirStats && err == nil && !sub.unlisted {
+			statsOf(item.Items).apply(item, i.Cfg.DateFormat)
+		}
+	}
+
+	if i.Cfg.DirStats {
+		i.sort(&data.Items)
+	}
+
+	return data, nil
+}
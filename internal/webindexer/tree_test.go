@@ -0,0 +1,84 @@
+package webindexer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_Tree(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	files := []string{"file.txt", "sub/nested.txt", "sub/deeper/deep.txt", "private/.noindex", "private/secret.txt"}
+	for _, name := range files {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(name), 0o644))
+	}
+
+	cfg := Config{
+		Source:       sourceDir,
+		Target:       targetDir,
+		SortBy:       "name",
+		Order:        "asc",
+		IndexFile:    "index.html",
+		BasePath:     sourceDir,
+		DateFormat:   "2006-01-02",
+		NoIndexFiles: []string{".noindex"},
+		Formats:      []string{"html", "json"},
+		Tree:         true,
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.DirsScanned)
+	assert.Equal(t, 2, result.IndexesWritten)
+
+	// Only the root has an index
+	assert.NoFileExists(t, filepath.Join(targetDir, "sub", "index.html"))
+
+	content, err := os.ReadFile(filepath.Join(targetDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `<span class="icon">📁</span>sub`)
+	assert.Contains(t, string(content), `<a href="sub/deeper/deep.txt">deep.txt</a>`)
+	assert.NotContains(t, string(content), "private")
+
+	content, err = os.ReadFile(filepath.Join(targetDir, "index.json"))
+	require.NoError(t, err)
+
+	var listing jsonListing
+	require.NoError(t, json.Unmarshal(content, &listing))
+	require.Len(t, listing.Items, 2)
+	sub := listing.Items[1]
+	assert.Equal(t, "sub", sub.Name)
+	require.Len(t, sub.Items, 2)
+	assert.Equal(t, "sub/deeper/", sub.Items[0].URL)
+	require.Len(t, sub.Items[0].Items, 1)
+	assert.Equal(t, "sub/deeper/deep.txt", sub.Items[0].Items[0].URL)
+}
+
+func TestRender_TreeThemes(t *testing.T) {
+	data := Data{
+		Tree: true,
+		Items: []Item{
+			{Name: "sub", URL: "sub/", IsDir: true, Items: []Item{{Name: "nested.txt", URL: "sub/nested.txt"}}},
+		},
+	}
+
+	renderDataAllThemes(t, data, func(t *testing.T, content string) {
+		assert.Contains(t, content, "<details>")
+		assert.Contains(t, content, `<a href="sub/nested.txt">nested.txt</a>`)
+		assert.NotContains(t, content, `id="listing"`)
+	})
+}
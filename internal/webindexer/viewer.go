@@ -0,0 +1,191 @@
+package webindexer
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/log"
+)
+
+// viewerDir is the hidden directory viewer pages are written to, next to the
+// files, such as .viewer/run.sh.html for run.sh.
+const viewerDir = ".viewer"
+
+// Viewer is the highlighted content of a text file, shown on its viewer page.
+type Viewer struct {
+	Item Item
+	// Language is the name of the language the file is highlighted as.
+	Language string
+	// Code is the highlighted content of the file.
+	Code template.HTML
+	// Style is the stylesheet of the highlighted code, in the palette of the
+	// theme.
+	Style template.CSS
+}
+
+// viewerStyles are the highlighting styles matching the palette of each
+// theme, in light and dark mode.
+var viewerStyles = map[Theme][2]string{
+	ThemeDefault:   {"github", "github-dark"},
+	ThemeSolarized: {"solarized-light", "solarized-dark"},
+	ThemeNord:      {"nord", "nord"},
+	ThemeDracula:   {"dracula", "dracula"},
+}
+
+// viewerFormatter renders highlighted code with CSS classes, so that the
+// light and dark styles can share the markup.
+var viewerFormatter = chromahtml.New(
+	chromahtml.WithClasses(true),
+	chromahtml.WithLineNumbers(true),
+	chromahtml.WithLinkableLineNumbers(true, "L"),
+)
+
+// viewerFile returns the name of the viewer page of a file.
+func viewerFile(name string) string {
+	return path.Join(viewerDir, name+pageSuffix)
+}
+
+// validateViewer checks the size limit of viewer pages.
+func (c Config) validateViewer() error {
+	if c.Viewer && c.ViewerMaxSize <= 0 {
+		return fmt.Errorf("viewer_max_size must be positive")
+	}
+
+	return nil
+}
+
+// viewable reports whether an item gets a viewer page: a file within the size
+// limit whose language is known by its name.
+func (c Config) viewable(item Item) bool {
+	if !c.Viewer || item.IsDir || item.SizeBytes > c.ViewerMaxSize {
+		return false
+	}
+
+	return lexers.Match(item.Name) != nil
+}
+
+// highlight renders the content of a file to HTML, highlighted as the
+// language matching its name.
+func highlight(name string, content []byte) (template.HTML, string, error) {
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	tokens, err := lexer.Tokenise(nil, strings.ToValidUTF8(string(content), "\uFFFD"))
+	if err != nil {
+		return "", "", fmt.Errorf("unable to tokenise: %w", err)
+	}
+
+	// The style only applies to inline styles, which the formatter doesn't
+	// use as the stylesheet comes from viewerStyle.
+	var b strings.Builder
+	if err := viewerFormatter.Format(&b, styles.Fallback, tokens); err != nil {
+		return "", "", fmt.Errorf("unable to format: %w", err)
+	}
+
+	return template.HTML(b.String()), lexer.Config().Name, nil // #nosec G203 -- escaped by chroma
+}
+
+// viewerStyle returns the stylesheet of highlighted code for a theme, using
+// its light and dark styles as the browser prefers.
+func viewerStyle(theme Theme) (template.CSS, error) {
+	var b strings.Builder
+	for n, scheme := range []string{"light", "dark"} {
+		fmt.Fprintf(&b, "@media (prefers-color-scheme: %s) {\n", scheme)
+		if err := viewerFormatter.WriteCSS(&b, styles.Get(viewerStyles[theme][n])); err != nil {
+			return "", fmt.Errorf("unable to write highlighting styles: %w", err)
+		}
+		b.WriteString("}\n")
+	}
+
+	return template.CSS(b.String()), nil // #nosec G203 -- generated by chroma
+}
+
+// viewerData returns the template data of the viewer page of a file in the
+// directory of data, with the stylesheet of the run.
+func (i Indexer) viewerData(ctx context.Context, data Data, item Item, style template.CSS) (Data, error) {
+	reader, ok := i.Source.(FileReader)
+	if !ok {
+		return Data{}, fmt.Errorf("source doesn't support reading files")
+	}
+
+	file, err := reader.Open(ctx, data.Path, item.Name)
+	if err != nil {
+		return Data{}, fmt.Errorf("unable to open %s: %w", item.Name, err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, i.Cfg.ViewerMaxSize))
+	if err != nil {
+		return Data{}, fmt.Errorf("unable to read %s: %w", item.Name, err)
+	}
+
+	code, language, err := highlight(item.Name, content)
+	if err != nil {
+		return Data{}, fmt.Errorf("unable to highlight %s: %w", item.Name, err)
+	}
+
+	viewer := fileData(data, item)
+	viewer.Viewer = &Viewer{Item: pageItem(item), Language: language, Code: code, Style: style}
+
+	return viewer, nil
+}
+
+// writeViewerPages writes a viewer page for each viewable file of a listing,
+// if enabled. Files that can't be read or highlighted are listed without a
+// viewer page, so this is done before the listing is written. Like detail
+// pages, viewer pages always use the theme.
+func (i Indexer) writeViewerPages(ctx context.Context, r *run, data *Data) error {
+	if !i.Cfg.Viewer {
+		return nil
+	}
+
+	for n := range data.Items {
+		item := &data.Items[n]
+		if item.ViewerURL == "" {
+			continue
+		}
+
+		if r.viewerStyle == "" {
+			style, err := viewerStyle(i.Cfg.ThemeValue())
+			if err != nil {
+				return err
+			}
+			r.viewerStyle = style
+		}
+
+		viewer, err := i.viewerData(ctx, *data, *item, r.viewerStyle)
+		if err != nil {
+			log.Warnf("Unable to make a viewer page of %s in %s: %v", item.Name, data.Path, err)
+			item.ViewerURL = ""
+			continue
+		}
+
+		o := output{
+			kind:        outputFile,
+			format:      FormatHTML,
+			file:        viewerFile(item.Name),
+			contentType: contentTypeHTML,
+		}
+		content, err := i.render(o, viewer)
+		if err != nil {
+			return fmt.Errorf("unable to render viewer page of %s: %w", item.Name, err)
+		}
+
+		if err := i.writeOutput(ctx, r, o, *data, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
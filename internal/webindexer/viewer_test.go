@@ -0,0 +1,191 @@
+package webindexer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewable(t *testing.T) {
+	cfg := Config{Viewer: true, ViewerMaxSize: 100}
+
+	assert.True(t, cfg.viewable(Item{Name: "run.sh", SizeBytes: 10}))
+	assert.True(t, cfg.viewable(Item{Name: "config.yaml", SizeBytes: 100}))
+	assert.False(t, cfg.viewable(Item{Name: "run.sh", SizeBytes: 101}))
+	assert.False(t, cfg.viewable(Item{Name: "app.tar.gz", SizeBytes: 10}))
+	assert.False(t, cfg.viewable(Item{Name: "scripts/", IsDir: true}))
+	assert.False(t, Config{ViewerMaxSize: 100}.viewable(Item{Name: "run.sh", SizeBytes: 10}))
+}
+
+func TestValidateViewer(t *testing.T) {
+	assert.NoError(t, Config{}.validateViewer())
+	assert.NoError(t, Config{Viewer: true, ViewerMaxSize: 1}.validateViewer())
+	assert.EqualError(t, Config{Viewer: true}.validateViewer(), "viewer_max_size must be positive")
+}
+
+func TestHidePages_Viewer(t *testing.T) {
+	items := []Item{
+		{Name: "run.sh"},
+		{Name: "run.sh.view.html"},
+		{Name: ".viewer", IsDir: true},
+		{Name: ".details", IsDir: true},
+	}
+
+	assert.Equal(t, []Item{
+		{Name: "run.sh"},
+		{Name: "run.sh.view.html"},
+		{Name: ".details", IsDir: true},
+	}, visibleItems(Config{Viewer: true}, items))
+}
+
+func TestHighlight(t *testing.T) {
+	code, language, err := highlight("main.go", []byte("package main\n\nfunc main() {}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "Go", language)
+	assert.Contains(t, string(code), `class="chroma"`)
+	assert.Contains(t, string(code), `<span class="kn">package</span>`)
+	assert.Contains(t, string(code), `id="L3"`)
+
+	code, language, err = highlight("page.html", []byte("<script>alert(1)</script>"))
+	require.NoError(t, err)
+	assert.Equal(t, "HTML", language)
+	assert.NotContains(t, string(code), "<script>")
+}
+
+func TestViewerStyle(t *testing.T) {
+	for _, theme := range []Theme{ThemeDefault, ThemeSolarized, ThemeNord, ThemeDracula} {
+		style, err := viewerStyle(theme)
+		require.NoError(t, err)
+		assert.Contains(t, string(style), "@media (prefers-color-scheme: light)")
+		assert.Contains(t, string(style), "@media (prefers-color-scheme: dark)")
+		assert.Contains(t, string(style), ".chroma")
+	}
+
+	// The dark solarized style uses its own background
+	style, err := viewerStyle(ThemeSolarized)
+	require.NoError(t, err)
+	assert.Contains(t, string(style), "#002b36")
+}
+
+func TestGenerate_ViewerPages(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh"), []byte("#!/bin/sh\necho  hello\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "app.tar.gz"), []byte{0x1f, 0x8b}, 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(sourceDir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "sub", "main.py"), []byte("print('hi')\n"), 0o644))
+	// A file named like the viewer page of another one is a file of its own
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh.view.html"), []byte("<p>real</p>"), 0o644))
+
+	cfg := testConfig(sourceDir, sourceDir)
+	cfg.Recursive = true
+	cfg.Viewer = true
+	cfg.ViewerMaxSize = 1 << 20
+	cfg.Minify = true
+
+	renderAllThemes(t, cfg, func(t *testing.T, index string) {
+		assert.Contains(t, index, `<a class="view" href=".viewer/run.sh.html"`)
+		assert.NotContains(t, index, `.viewer/app.tar.gz.html`)
+		assert.Contains(t, index, `<a href="run.sh.view.html">`)
+		// Viewer pages from earlier runs aren't listed
+		assert.NotContains(t, index, `<a href=".viewer/">`)
+
+		real, err := os.ReadFile(filepath.Join(sourceDir, "run.sh.view.html"))
+		require.NoError(t, err)
+		assert.Equal(t, "<p>real</p>", string(real))
+
+		viewer, err := os.ReadFile(filepath.Join(sourceDir, ".viewer", "run.sh.html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(viewer), `<a href="../run.sh">Download run.sh</a>`)
+		assert.Contains(t, string(viewer), "Bash")
+		assert.Contains(t, string(viewer), "prefers-color-scheme")
+		// Minifying keeps the whitespace of the code
+		assert.Contains(t, string(viewer), "echo</span>  hello")
+
+		_, err = os.Stat(filepath.Join(sourceDir, "sub", ".viewer", "main.py.html"))
+		assert.NoError(t, err)
+	})
+}
+
+// unreadableSource is a local source that fails to open one file.
+type unreadableSource struct {
+	*LocalBackend
+	name string
+}
+
+func (u unreadableSource) Open(ctx context.Context, path, name string) (io.ReadCloser, error) {
+	if name == u.name {
+		return nil, errors.New("permission denied")
+	}
+
+	return u.LocalBackend.Open(ctx, path, name)
+}
+
+func TestGenerate_ViewerPagesUnreadable(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	for _, name := range []string{"run.sh", "broken.sh"} {
+		require.NoError(t, os.WriteFile(filepath.Join(sourceDir, name), []byte("echo hello\n"), 0o644))
+	}
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Viewer = true
+	cfg.ViewerMaxSize = 1 << 20
+	cfg.DetailPages = true
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: unreadableSource{LocalBackend: &LocalBackend{path: sourceDir, cfg: cfg}, name: "broken.sh"},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	// The file that can't be read is listed without a viewer page
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	index, err := os.ReadFile(filepath.Join(targetDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `href=".viewer/run.sh.html"`)
+	assert.NotContains(t, string(index), `.viewer/broken.sh.html`)
+	assert.FileExists(t, filepath.Join(targetDir, ".viewer", "run.sh.html"))
+	assert.NoFileExists(t, filepath.Join(targetDir, ".viewer", "broken.sh.html"))
+
+	detail, err := os.ReadFile(filepath.Join(targetDir, ".details", "broken.sh.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(detail), `.viewer/broken.sh.html`)
+}
+
+func TestGenerate_ViewerPagesCustomTemplate(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "run.sh"), []byte("echo hello\n"), 0o644))
+	templateFile := filepath.Join(t.TempDir(), "custom.html")
+	require.NoError(t, os.WriteFile(templateFile, []byte(`<p>custom {{ len .Items }}</p>`), 0o644))
+
+	cfg := testConfig(sourceDir, targetDir)
+	cfg.Viewer = true
+	cfg.ViewerMaxSize = 1 << 20
+	cfg.Template = templateFile
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	_, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	index, err := os.ReadFile(filepath.Join(targetDir, "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<p>custom 1</p>", string(index))
+
+	// Viewer pages are always rendered with the theme
+	viewer, err := os.ReadFile(filepath.Join(targetDir, ".viewer", "run.sh.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(viewer), `<a href="../run.sh">Download run.sh</a>`)
+	assert.NotContains(t, string(viewer), "custom")
+}
@@ -2,16 +2,19 @@
 package webindexer
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"html/template"
 	"math"
 	"net/url"
-	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/charmbracelet/log"
@@ -36,10 +39,15 @@ type Indexer struct {
 	Target       FileSource
 	s3           *s3.S3
 	BackendSetup BackendSetup
+	// checksums caches the checksums of source files between runs.
+	checksums *checksumCache
 }
 
 // FileSource is an interface for listing the contents of a directory or S3
 // bucket.
+//
+// It is the original, non-cancellable interface. Sources should also
+// implement FileSourceV2, which the indexer prefers when available.
 type FileSource interface {
 	Read(path string) ([]Item, bool, error)
 	Write(data Data, content string) error
@@ -54,6 +62,61 @@ type Item struct {
 	URL          string
 	IsDir        bool
 	Items        []Item
+	// SizeBytes is the size of a file in bytes.
+	SizeBytes int64
+	// ModTime is the modification time of the item. It is zero for S3
+	// prefixes.
+	ModTime time.Time
+	// HasStats is true for directories when directory stats are enabled.
+	// Their Size, SizeBytes, LastModified and ModTime are then those of
+	// their contents.
+	HasStats bool
+	// FileCount is the number of files in a directory, recursively.
+	FileCount int
+	// TotalSize is the total size of the files in a directory, recursively.
+	TotalSize int64
+	// NewestModTime is the modification time of the most recently modified
+	// file in a directory, recursively.
+	NewestModTime time.Time
+	// Indexed is true for directories whose index files were written before
+	// those of their parent, which Markdown listings link to.
+	Indexed bool
+	// Description is the description of the item from the descriptions file
+	// or its sidecar file, when descriptions are enabled.
+	Description string
+	// Checksums are the checksums of a file with each configured algorithm.
+	Checksums []Checksum
+	// Companions are the files accompanying a file, such as its signature,
+	// which are listed with it rather than on their own.
+	Companions []Companion
+	// Verified is true when the checksums read from the companion checksum
+	// files of a file match its own.
+	Verified bool
+	// ChecksumMismatch is true when a companion checksum file of a file has a
+	// different checksum than the file itself.
+	ChecksumMismatch bool
+	// DetailURL is the URL of the detail page of a file, when enabled.
+	DetailURL string
+	// ViewerURL is the URL of the highlighted viewer page of a text file,
+	// when enabled.
+	ViewerURL string
+	// ThumbnailURL is the URL of the thumbnail of an image, when the gallery
+	// is enabled.
+	ThumbnailURL string
+	// Media is set for audio and video files played inline, when enabled.
+	Media *Media
+	// MimeType is the media type of a file without parameters, such as
+	// image/png, from its extension, content or S3 metadata.
+	MimeType string
+	// Kind is the kind of an item, such as KindArchive or KindImage, which
+	// the themes show with different icons.
+	Kind string
+	// Icon overrides the theme's icon of the item's kind, when configured
+	// for its extension.
+	Icon string
+	// ETag and StorageClass are those of S3 objects.
+	ETag         string
+	StorageClass string
 }
 
 // Data holds the template data.
@@ -66,6 +129,48 @@ type Data struct {
 	Items        []Item
 	Parent       string
 	HasParent    bool
+	// Tree is true when the page lists the whole tree, with the contents of
+	// each directory in the Items of its item.
+	Tree bool
+	// RootURL is the URL of the root of the tree, relative to the page
+	// unless a base URL is configured.
+	RootURL string
+	// SearchIndex is the URL of the search index, if enabled.
+	SearchIndex string
+	// Breadcrumbs link to each ancestor of the directory, from the root down
+	// to the directory itself.
+	Breadcrumbs []Breadcrumb
+	// Pagination is set when the listing is split across several pages.
+	Pagination *Pagination
+	// Header and Readme are the rendered header and readme files of the
+	// directory, shown above and below the listing.
+	Header template.HTML
+	Readme template.HTML
+	// HasDescriptions is true when any of the items has a description, so
+	// that themes only show the description column when it's needed.
+	HasDescriptions bool
+	// HasChecksums is true when any of the items has checksums.
+	HasChecksums bool
+	// Detail is set on the detail page of a file, instead of Items.
+	Detail *Detail
+	// Viewer is set on the viewer page of a text file, instead of Items.
+	Viewer *Viewer
+	// Gallery is true when the items are shown as a grid of thumbnails.
+	Gallery bool
+	// Layout is the default layout of the items, which visitors can switch.
+	Layout Layout
+
+	// unlisted is true when the contents of the directory weren't listed
+	// because it contains a skipindex file.
+	unlisted bool
+}
+
+// Breadcrumb is a link to a directory in the path of the current one.
+type Breadcrumb struct {
+	Name string
+	URL  string
+	// Current is true for the directory being listed, the last breadcrumb.
+	Current bool
 }
 
 type BackendSetup interface {
@@ -89,6 +194,7 @@ func New(cfg Config) (*Indexer, error) {
 	if err := indexer.Cfg.Validate(); err != nil {
 		return nil, err
 	}
+	indexer.Cfg.sortFileTypes()
 
 	if err := indexer.BackendSetup.Setup(indexer); err != nil {
 		return nil, err
@@ -146,7 +252,7 @@ func setupBackends(indexer *Indexer) error {
 
 	if isS3URI(indexer.Cfg.Source) || isS3URI(indexer.Cfg.Target) {
 		log.Debug("Setting up S3 session")
-		sess, err := session.NewSession()
+		sess, err := newS3Session()
 		if err != nil {
 			return fmt.Errorf("failed to create AWS session: %w", err)
 		}
@@ -174,6 +280,13 @@ func setupBackends(indexer *Indexer) error {
 		}
 	}
 
+	if indexer.Cfg.ChecksumCache != "" {
+		indexer.checksums, err = loadChecksumCache(indexer.Cfg.ChecksumCache)
+		if err != nil {
+			return err
+		}
+	}
+
 	indexer.Source, err = setupBackend(indexer.Cfg.Source, indexer)
 	if err != nil {
 		return err
@@ -187,96 +300,435 @@ func setupBackends(indexer *Indexer) error {
 	return nil
 }
 
+// newS3Session returns the AWS session of the S3 backends. The SDK's own
+// retries are disabled, since withRetry retries S3 requests as configured and
+// counts each attempt.
+func newS3Session(configs ...*aws.Config) (*session.Session, error) {
+	return session.NewSession(append([]*aws.Config{{MaxRetries: aws.Int(0)}}, configs...)...)
+}
+
 // setupBackend sets up the backend for the given URI.
 func setupBackend(uri string, indexer *Indexer) (FileSource, error) {
 	log.Debugf("Setting up backend for %s", uri)
 	if isS3URI(uri) {
 		bucket, _ := uriToBucketAndPrefix(uri)
-		return &S3Backend{svc: indexer.s3, bucket: bucket, cfg: indexer.Cfg}, nil
+		return &S3Backend{svc: indexer.s3, bucket: bucket, cfg: indexer.Cfg, checksums: indexer.checksums}, nil
 	}
-	return &LocalBackend{path: uri, cfg: indexer.Cfg}, nil
+	return &LocalBackend{path: uri, cfg: indexer.Cfg, checksums: indexer.checksums}, nil
+}
+
+// run tracks the progress of a single call to Generate.
+type run struct {
+	result *Result
+	// written holds the relative paths of the index files written so far.
+	written []string
+	// root is the relative path of the directory Generate was called for.
+	root string
+	// rootPath is the path Generate was called for.
+	rootPath string
+	// feed holds the most recently modified files of the tree so far.
+	feed []feedEntry
+	// sitemap holds the URLs for the sitemap.
+	sitemap []sitemapURL
+	// search holds the paths for the search index.
+	search []string
+	// viewerStyle is the stylesheet of viewer pages, made for the first one.
+	viewerStyle template.CSS
 }
 
-// Generate the index file for the given path.
-func (i Indexer) Generate(path string) error {
+// Generate the index file for the given path, recursing into subdirectories
+// when configured. If ctx is cancelled, or the configured timeout expires,
+// generation stops once any in-flight write has finished.
+//
+// In keep-going mode, directories that fail are recorded and generation
+// continues. A *FailedPathsError listing them is returned at the end.
+//
+// The returned Result summarizes the run, including when an error is
+// returned.
+func (i Indexer) Generate(ctx context.Context, path string) (*Result, error) {
+	start := time.Now()
+
+	if i.Cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, i.Cfg.Timeout,
+			fmt.Errorf("timeout of %s exceeded: %w", i.Cfg.Timeout, context.DeadlineExceeded))
+		defer cancel()
+	}
+
+	r := &run{result: &Result{}, rootPath: path}
+	if root, err := i.data(nil, path); err == nil {
+		r.root = root.RelativePath
+	}
+
 	var err error
+	if i.Cfg.Tree {
+		err = i.generateTree(ctx, r, path)
+	} else {
+		_, err = i.generate(ctx, r, path)
+	}
+	if err == nil {
+		err = i.writeRootFiles(ctx, r, path)
+		if err != nil {
+			err = i.fail(ctx, r, path, err)
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		log.Warnf("Stopped after writing %d index file(s)", len(r.written))
+		for _, written := range r.written {
+			log.Debugf("Completed %s", written)
+		}
+
+		r.result.Interrupted = true
+		err = fmt.Errorf("interrupted after writing %d index file(s): %w", len(r.written), context.Cause(ctx))
+	}
+
+	if err == nil && len(r.result.Failures) > 0 {
+		err = &FailedPathsError{Failures: r.result.Failures}
+	}
 
-	items, hasNoIndex, err := i.Source.Read(path)
+	if err := i.checksums.save(); err != nil {
+		log.Warnf("Unable to save checksum cache: %v", err)
+	}
+
+	r.result.Duration = time.Since(start)
+	r.result.Err = err
+	r.result.logSummary()
+
+	return r.result, err
+}
+
+// generate writes the index file for the given path and recurses into its
+// subdirectories, returning the stats of the directory.
+func (i Indexer) generate(ctx context.Context, r *run, path string) (dirStats, error) {
+	if err := ctx.Err(); err != nil {
+		return dirStats{}, err
+	}
+
+	listing, err := i.list(ctx, r, path)
 	if err != nil {
-		return err
+		return dirStats{unknown: true}, i.fail(ctx, r, path, err)
 	}
 
-	// If hasNoIndex is true, skip this directory entirely
-	if hasNoIndex {
+	// If the directory has a noindex file, skip it entirely
+	if listing.Marker == MarkerNoIndex {
 		log.Debugf("Skipping generation for %s due to noindex file", path)
-		return nil
+		return dirStats{}, nil
 	}
+	items := listing.Items
 
 	// Prepare template data regardless of whether items were found
 	data, err := i.data(items, path)
 	if err != nil {
-		return err
+		return dirStats{unknown: true}, i.fail(ctx, r, path, err)
+	}
+	data.Header = listing.Notes.Header
+	data.Readme = listing.Notes.Readme
+
+	// With directory stats, subdirectories are processed first so that their
+	// totals are known when this directory's index is written. Markdown
+	// listings also need to know which subdirectories got an index to link
+	// to.
+	childrenFirst := i.Cfg.DirStats || (i.Cfg.Recursive && i.Cfg.hasFormat(FormatMarkdown))
+	if childrenFirst {
+		for n := range data.Items {
+			stats, err := i.parseItem(ctx, r, path, data.Items[n])
+			if err != nil {
+				return dirStats{}, err
+			}
+			if !data.Items[n].IsDir {
+				continue
+			}
+			data.Items[n].Indexed = stats.indexed
+			if i.Cfg.DirStats {
+				stats.apply(&data.Items[n], i.Cfg.DateFormat)
+			}
+		}
+		i.sort(&data.Items)
+	}
+
+	// A directory whose index can't be written may still have subdirectories
+	// that can, so only stop here if the failure isn't recorded.
+	indexed := len(data.Items) > 0
+	if err := i.writeIndex(ctx, r, path, data); err != nil {
+		indexed = false
+		if err := i.fail(ctx, r, path, err); err != nil {
+			return dirStats{}, err
+		}
+	}
+
+	if !childrenFirst {
+		// Process items to handle recursion.
+		// This loop won't execute if items is empty.
+		for _, item := range items { // Iterate over original items
+			if _, err := i.parseItem(ctx, r, path, item); err != nil {
+				// Return the error to propagate it up.
+				return dirStats{}, err
+			}
+		}
+	}
+
+	// The contents of a skipindex directory aren't listed, so its stats
+	// aren't known either.
+	if listing.Marker == MarkerSkipIndex {
+		return dirStats{unknown: true}, nil
+	}
+
+	stats := statsOf(data.Items)
+	stats.indexed = indexed
+
+	return stats, nil
+}
+
+// list lists a directory from the source and counts it in the result.
+func (i Indexer) list(ctx context.Context, r *run, path string) (Listing, error) {
+	return i.listFrom(ctx, r, i.source(), path)
+}
+
+// listFrom lists the directory at path from the given source, like list.
+func (i Indexer) listFrom(ctx context.Context, r *run, source FileSourceV2, path string) (Listing, error) {
+	listing, err := source.List(ctx, path)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	// The sitemap is only written to the root, so files of the same name
+	// further down are left in their listings.
+	if i.Cfg.Sitemap && path == r.rootPath {
+		listing.Items = slices.DeleteFunc(listing.Items, func(item Item) bool {
+			return !item.IsDir && isSitemapFile(item.Name)
+		})
+	}
+
+	r.result.DirsScanned++
+	r.result.NoIndexHits += len(listing.NoIndexDirs)
+	switch listing.Marker {
+	case MarkerNoIndex:
+		r.result.NoIndexHits++
+	case MarkerSkipIndex:
+		r.result.SkipIndexHits++
+	case MarkerNone:
 	}
+	r.result.ItemsListed += len(listing.Items)
 
+	return listing, nil
+}
+
+// writeIndex renders and writes the index files for a directory, one for
+// each configured output format.
+func (i Indexer) writeIndex(ctx context.Context, r *run, path string, data Data) error {
 	// Ensure the target directory exists before attempting to write or recurse
-	if err := i.Target.EnsureDirExists(data.RelativePath); err != nil {
+	if err := i.target().EnsureDirExistsContext(ctx, data.RelativePath); err != nil {
 		return fmt.Errorf("failed to ensure target directory exists for %s: %w", data.RelativePath, err)
 	}
 
 	// Only generate and write the index file if there are items to list.
 	// This handles the skipindex case (Read returns empty items) and empty directories.
-	if len(items) > 0 {
-		var tmpl *template.Template
-		var templStr string
-		if i.Cfg.Template != "" {
-			log.Debugf("Using custom template %s for %s", i.Cfg.Template, path)
-			templBytes, err := os.ReadFile(i.Cfg.Template)
-			if err != nil {
+	if len(data.Items) == 0 {
+		log.Debugf("Skipping index file generation for %s (no items or skipindex found)", path)
+		r.result.IndexesSkipped++
+
+		// Remove the extra pages of an earlier listing. The first page is
+		// left alone, as it may not be one the indexer wrote.
+		for _, o := range i.Cfg.outputs() {
+			if err := i.removeStalePages(ctx, o, data, 1); err != nil {
 				return err
 			}
-			templStr = string(templBytes)
-		} else {
-			log.Debugf("Using %s theme template for %s", i.Cfg.Theme, path)
-			templStr = getThemeTemplate(i.Cfg.Theme)
 		}
 
-		tmpl, err = template.New("index").Parse(templStr)
-		if err != nil {
-			return err
+		return nil
+	}
+
+	if err := i.writeThumbnails(ctx, r, &data); err != nil {
+		return err
+	}
+	i.probeMedia(ctx, &data)
+
+	data.RootURL = i.rootURL(r, data)
+	if i.Cfg.Search {
+		data.SearchIndex = data.RootURL + searchIndexFile
+	}
+
+	if err := i.writeViewerPages(ctx, r, &data); err != nil {
+		return err
+	}
+
+	for _, o := range i.Cfg.outputs() {
+		pages := i.pages(o, data)
+		for _, p := range pages {
+			content, err := i.render(p.output, p.data)
+			if err != nil {
+				return err
+			}
+
+			if err := i.writeOutput(ctx, r, p.output, p.data, content); err != nil {
+				return err
+			}
 		}
 
-		generated := new(strings.Builder)
-		if err := tmpl.Execute(generated, data); err != nil {
+		if err := i.removeStalePages(ctx, o, data, len(pages)); err != nil {
 			return err
 		}
+	}
 
-		output := generated.String()
-		if i.Cfg.Minify {
-			output = minifyHTML(generated.String())
-		}
+	if err := i.writeChecksumFiles(ctx, r, data); err != nil {
+		return err
+	}
 
-		if err := i.Target.Write(data, output); err != nil {
-			return err
+	if err := i.writeDetailPages(ctx, r, data); err != nil {
+		return err
+	}
+
+	i.collectSitemap(r, data)
+	i.collectSearch(r, data)
+
+	return i.collectFeed(ctx, r, data)
+}
+
+// writeRootFiles writes the files covering the whole tree, such as the feed
+// and sitemap, to its root directory once all directories have been indexed.
+func (i Indexer) writeRootFiles(ctx context.Context, r *run, path string) error {
+	root, err := i.data(nil, path)
+	if err != nil {
+		return err
+	}
+
+	if err := i.writeTreeFeed(ctx, r, root); err != nil {
+		return err
+	}
+
+	if err := i.writeSearchIndex(ctx, r, root); err != nil {
+		return err
+	}
+
+	return i.writeSitemap(ctx, r, root)
+}
+
+// writeOutput writes a rendered file for a directory, unless the target
+// already has it with the same content. Indexes and other generated files are
+// counted separately.
+func (i Indexer) writeOutput(ctx context.Context, r *run, o output, data Data, content []byte) error {
+	if i.unchanged(ctx, data.RelativePath, o.file, content) {
+		log.Infof("%s for %s is unchanged", o.file, data.RelativePath)
+		if o.kind == outputIndex {
+			r.result.IndexesUnchanged++
+		} else {
+			r.result.FilesUnchanged++
 		}
+		return nil
+	}
+
+	// Don't start new writes once cancelled, but let a write that has
+	// started finish so the target isn't left with a partial index.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	writeCtx := context.WithoutCancel(ctx)
+	var err error
+	if o.kind == outputIndex && o.format == FormatHTML && o.file == i.Cfg.IndexFile {
+		err = i.target().WriteContext(writeCtx, data, string(content))
 	} else {
-		// Log if we are skipping the write due to empty items (skipindex or empty dir)
-		log.Debugf("Skipping index file generation for %s (no items or skipindex found)", path)
+		err = i.writeFile(writeCtx, data.RelativePath, o.file, content, o.contentType)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Process items to handle recursion.
-	// This loop won't execute if items is empty.
-	for _, item := range items { // Iterate over original items
-		err := i.parseItem(path, item) // Pass item by value, check error
-		if err != nil {
-			// Stop processing if any subdirectory fails? Or just log?
-			// Return the error to propagate it up.
-			return err
-		}
+	if o.kind == outputIndex {
+		r.written = append(r.written, path.Join(data.RelativePath, o.file))
+		r.result.IndexesWritten++
+	} else {
+		r.result.FilesWritten++
+	}
+	r.result.BytesUploaded += int64(len(content))
+
+	return nil
+}
+
+// writeFile writes a file other than the HTML index to the target, which
+// must implement FileWriter.
+func (i Indexer) writeFile(ctx context.Context, dir, name string, content []byte, contentType string) error {
+	writer, ok := i.target().(FileWriter)
+	if !ok {
+		return fmt.Errorf("target doesn't support writing %s", name)
+	}
+
+	return writer.WriteFile(ctx, dir, name, content, contentType)
+}
+
+// unchanged reports whether the target already has the given content for a
+// file, if the target supports comparing content. Errors are logged and
+// treated as changed so the file is written anyway.
+func (i Indexer) unchanged(ctx context.Context, dir, name string, content []byte) bool {
+	comparer, ok := i.target().(ContentComparer)
+	if !ok {
+		return false
 	}
 
+	unchanged, err := comparer.Unchanged(ctx, dir, name, content)
+	if err != nil {
+		log.Debugf("Unable to compare existing %s for %s: %v", name, dir, err)
+		return false
+	}
+
+	return unchanged
+}
+
+// fail records err as a failure of path in keep-going mode, so that
+// generation can continue. Otherwise, or if the run was cancelled, err is
+// returned as-is.
+func (i Indexer) fail(ctx context.Context, r *run, path string, err error) error {
+	if !i.Cfg.KeepGoing || ctx.Err() != nil {
+		return err
+	}
+
+	log.Errorf("Failed to index %s, continuing: %v", path, err)
+	r.result.Failures = append(r.result.Failures, Failure{Path: path, Err: err})
+
 	return nil
 }
 
+// Failure records a directory that could not be indexed.
+type Failure struct {
+	Path string
+	Err  error
+}
+
+// FailedPathsError is returned by Generate in keep-going mode when one or more
+// directories could not be indexed.
+type FailedPathsError struct {
+	Failures []Failure
+}
+
+func (e *FailedPathsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d path(s) failed:", len(e.Failures))
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "\n  %s: %v", f.Path, f.Err)
+	}
+
+	return b.String()
+}
+
+func (e *FailedPathsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+
+	return errs
+}
+
+// source returns the indexer's source as a FileSourceV2.
+func (i Indexer) source() FileSourceV2 {
+	return AdaptFileSource(i.Source)
+}
+
+// target returns the indexer's target as a FileSourceV2.
+func (i Indexer) target() FileSourceV2 {
+	return AdaptFileSource(i.Target)
+}
+
 // getThemeTemplate returns the template string for the given theme.
 func getThemeTemplate(theme string) string {
 	switch theme {
@@ -308,6 +760,9 @@ func (i Indexer) data(items []Item, path string) (Data, error) {
 		RelativePath: relativePath,
 		URL:          i.Cfg.BaseURL,
 		Title:        i.formatTitle(path, relativePath),
+		Breadcrumbs:  i.breadcrumbs(relativePath),
+		Gallery:      i.Cfg.Gallery,
+		Layout:       i.Cfg.LayoutValue(),
 	}
 
 	if path == i.Cfg.BasePath {
@@ -331,6 +786,8 @@ func (i Indexer) data(items []Item, path string) (Data, error) {
 			return Data{}, err
 		}
 		processedItems = append(processedItems, processedItem)
+		data.HasDescriptions = data.HasDescriptions || processedItem.Description != ""
+		data.HasChecksums = data.HasChecksums || len(processedItem.Checksums) > 0
 	}
 	data.Items = processedItems // Assign processed items with URLs
 
@@ -338,6 +795,51 @@ func (i Indexer) data(items []Item, path string) (Data, error) {
 	return data, nil
 }
 
+// breadcrumbs returns the links to the root and each directory down to the
+// one at relativePath. Without a base URL, the links are relative.
+func (i Indexer) breadcrumbs(relativePath string) []Breadcrumb {
+	parts := strings.FieldsFunc(relativePath, func(r rune) bool { return r == '/' })
+
+	rootName := path.Base(strings.TrimSuffix(filepath.ToSlash(i.Cfg.BasePath), "/"))
+	if rootName == "." || rootName == "/" {
+		rootName = "/"
+	}
+
+	crumbs := make([]Breadcrumb, 0, len(parts)+1)
+	crumbs = append(crumbs, Breadcrumb{Name: rootName, URL: i.breadcrumbURL(nil, len(parts))})
+	for n, part := range parts {
+		crumbs = append(crumbs, Breadcrumb{Name: part, URL: i.breadcrumbURL(parts[:n+1], len(parts)-n-1)})
+	}
+	crumbs[len(crumbs)-1].Current = true
+
+	return crumbs
+}
+
+// breadcrumbURL returns the URL of the directory made of parts, which is up
+// levels above the current one.
+func (i Indexer) breadcrumbURL(parts []string, up int) string {
+	var crumbURL string
+	switch {
+	case i.Cfg.BaseURL != "":
+		var err error
+		crumbURL, err = joinURL(i.Cfg.BaseURL, parts...)
+		if err != nil {
+			log.Error("Error joining URL:", err)
+		}
+		crumbURL = strings.TrimSuffix(crumbURL, "/") + "/"
+	case up == 0:
+		crumbURL = "./"
+	default:
+		crumbURL = strings.Repeat("../", up)
+	}
+
+	if i.Cfg.LinkToIndexes {
+		crumbURL += i.Cfg.IndexFile
+	}
+
+	return crumbURL
+}
+
 // processItemForData generates the URL for an item. Does NOT handle recursion.
 func (i Indexer) processItemForData(path string, item Item) (Item, error) {
 	// Calculate the relative path by removing the base path
@@ -348,23 +850,55 @@ func (i Indexer) processItemForData(path string, item Item) (Item, error) {
 	}
 
 	item.URL = resolveItemURL(i.Cfg.BaseURL, relativePath, item.Name, item.IsDir, i.Cfg.LinkToIndexes, i.Cfg.IndexFile)
+	if i.Cfg.DetailPages && !item.IsDir {
+		item.DetailURL = resolveItemURL(i.Cfg.BaseURL, relativePath, detailFile(item.Name), false, false, "")
+	}
+	if i.Cfg.viewable(item) {
+		item.ViewerURL = resolveItemURL(i.Cfg.BaseURL, relativePath, viewerFile(item.Name), false, false, "")
+	}
+	item = i.Cfg.fileType(item)
+	item.Media = i.Cfg.media(item)
+	if i.Cfg.thumbnailable(item) {
+		thumbnail, _ := thumbnailFile(item.Name)
+		item.ThumbnailURL = resolveItemURL(i.Cfg.BaseURL, relativePath, thumbnail, false, false, "")
+	}
+	for n := range item.Companions {
+		item.Companions[n].URL = resolveItemURL(i.Cfg.BaseURL, relativePath, item.Companions[n].Name, false, false, "")
+	}
 	// Return the item with the URL set
 	return item, nil
 }
 
-// parseItem handles the recursive call for directories.
-func (i Indexer) parseItem(path string, item Item) error {
-	// If the item is a directory and recursive mode is enabled, generate its index
-	if item.IsDir && i.Cfg.Recursive {
-		// Construct the full path for the subdirectory
-		subDirPath := filepath.Join(path, item.Name)
-		if err := i.Generate(subDirPath); err != nil {
-			// Log the error but also return it to stop processing this branch
-			log.Errorf("Error generating index for subdirectory %s: %v", subDirPath, err)
-			return fmt.Errorf("error generating index for subdirectory %s: %w", subDirPath, err)
+// parseItem handles the recursive call for directories, returning the stats
+// of the directory.
+func (i Indexer) parseItem(ctx context.Context, r *run, path string, item Item) (dirStats, error) {
+	if !item.IsDir || (!i.Cfg.Recursive && !i.Cfg.DirStats) {
+		return dirStats{}, nil
+	}
+
+	// Construct the full path for the subdirectory
+	subDirPath := filepath.Join(path, item.Name)
+
+	// If recursive mode is enabled, generate its index. Otherwise, it is only
+	// listed for its stats.
+	var stats dirStats
+	var err error
+	if i.Cfg.Recursive {
+		stats, err = i.generate(ctx, r, subDirPath)
+	} else {
+		stats, err = i.walkStats(ctx, r, subDirPath)
+	}
+	if err != nil {
+		// Cancellation isn't a failure of this subdirectory
+		if ctx.Err() != nil {
+			return dirStats{}, err
 		}
+		// Log the error but also return it to stop processing this branch
+		log.Errorf("Error generating index for subdirectory %s: %v", subDirPath, err)
+		return dirStats{}, fmt.Errorf("error generating index for subdirectory %s: %w", subDirPath, err)
 	}
-	return nil // Return nil error if no recursion error occurred
+
+	return stats, nil
 }
 
 func (i Indexer) formatTitle(path, relativePath string) string {
@@ -388,6 +922,29 @@ func humanizeBytes(bytes int64) string {
 }
 
 func minifyHTML(str string) string {
+	// Preformatted text, such as previews, keeps its whitespace.
+	var b strings.Builder
+	for {
+		start := strings.Index(str, "<pre")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(str[start:], "</pre>")
+		if end < 0 {
+			break
+		}
+		end += start + len("</pre>")
+
+		b.WriteString(minifyWhitespace(str[:start]))
+		b.WriteString(str[start:end])
+		str = str[end:]
+	}
+	b.WriteString(minifyWhitespace(str))
+
+	return b.String()
+}
+
+func minifyWhitespace(str string) string {
 	str = strings.ReplaceAll(str, "\n", "")
 	str = strings.ReplaceAll(str, "\t", "")
 	str = strings.ReplaceAll(str, "  ", "")
@@ -1,10 +1,13 @@
 package webindexer
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -51,7 +54,7 @@ func TestIndexer_Generate(t *testing.T) {
 	// Write should NOT be called when Read returns empty items
 	// mockTarget.On("Write", mock.Anything, mock.Anything).Return(nil)
 
-	err := indexer.Generate("path/to/generate")
+	_, err := indexer.Generate(context.Background(), "path/to/generate")
 	assert.NoError(t, err)
 
 	mockSource.AssertExpectations(t)
@@ -94,7 +97,7 @@ func TestCustomTemplate(t *testing.T) {
 	// Write should NOT be called when Read returns empty items
 	// mockTarget.On("Write", mock.Anything, mock.Anything).Return(nil)
 
-	err = indexer.Generate("path/to/generate")
+	_, err = indexer.Generate(context.Background(), "path/to/generate")
 	assert.NoError(t, err)
 
 	// Check the file content
@@ -198,6 +201,12 @@ func TestMinifyHTML(t *testing.T) {
 	assert.False(t, strings.Contains(minified, "> <"))
 }
 
+func TestMinifyHTML_Pre(t *testing.T) {
+	html := "<div>\n  <pre class=\"preview\">line 1\n  line 2\n</pre>\n</div>\n<pre>a\n\tb</pre>\n"
+
+	assert.Equal(t, "<div><pre class=\"preview\">line 1\n  line 2\n</pre></div><pre>a\n\tb</pre>", minifyHTML(html))
+}
+
 func TestShouldSkipURL(t *testing.T) {
 	// URLs that should be skipped
 	skips := []string{
@@ -314,6 +323,49 @@ func TestIndexer_ProcessItemForData(t *testing.T) {
 	assert.Equal(t, expectUrlFile, modifiedItemFile.URL)
 }
 
+func TestIndexer_Breadcrumbs(t *testing.T) {
+	indexer := Indexer{Cfg: Config{BasePath: "/srv/files", IndexFile: "index.html"}}
+
+	assert.Equal(t, []Breadcrumb{
+		{Name: "files", URL: "../../"},
+		{Name: "a", URL: "../"},
+		{Name: "b", URL: "./", Current: true},
+	}, indexer.breadcrumbs("/a/b"))
+
+	assert.Equal(t, []Breadcrumb{{Name: "files", URL: "./", Current: true}}, indexer.breadcrumbs("/"))
+
+	indexer.Cfg.BaseURL = "https://example.com/files"
+	indexer.Cfg.LinkToIndexes = true
+	assert.Equal(t, []Breadcrumb{
+		{Name: "files", URL: "https://example.com/files/index.html"},
+		{Name: "a", URL: "https://example.com/files/a/index.html", Current: true},
+	}, indexer.breadcrumbs("/a"))
+
+	// The root of a bucket has no name of its own
+	indexer.Cfg.BasePath = ""
+	assert.Equal(t, "/", indexer.breadcrumbs("/")[0].Name)
+}
+
+func TestRender_Breadcrumbs(t *testing.T) {
+	data := Data{
+		HasParent: true,
+		Breadcrumbs: []Breadcrumb{
+			{Name: "files", URL: "../"},
+			{Name: "a", URL: "./", Current: true},
+		},
+	}
+
+	renderDataAllThemes(t, data, func(t *testing.T, content string) {
+		assert.Contains(t, content, `<a href="../">files</a><span class="separator">/</span><span>a</span>`)
+	})
+
+	// The root shows its own crumb too
+	root := Data{Breadcrumbs: []Breadcrumb{{Name: "files", URL: "./", Current: true}}}
+	renderDataAllThemes(t, root, func(t *testing.T, content string) {
+		assert.Contains(t, content, `<nav class="breadcrumbs"><span>files</span>`)
+	})
+}
+
 func TestGetThemeTemplate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -437,9 +489,231 @@ func TestGenerate_Recursive(t *testing.T) {
 	}), mock.AnythingOfType("string")).Return(nil).Once()
 
 	// 5. Call Generate from the root source path
-	err = indexer.Generate(absSourceDir)
+	_, err = indexer.Generate(context.Background(), absSourceDir)
 	require.NoError(t, err)
 
 	// 6. Assert mock expectations were met
 	mockTarget.AssertExpectations(t)
 }
+
+func TestGenerate_Cancelled(t *testing.T) {
+	mockSource := new(MockSource)
+	mockTarget := new(MockSource)
+	indexer := Indexer{
+		Source: mockSource,
+		Target: mockTarget,
+		Cfg: Config{
+			Recursive: true,
+			IndexFile: "index.html",
+			BasePath:  "/root",
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockSource.On("Read", "/root").Return([]Item{{Name: "subdir", IsDir: true}}, false, nil)
+	mockTarget.On("EnsureDirExists", "/").Return(nil)
+	// Cancel while the root index is being written. The write completes, but
+	// the subdirectory is never read.
+	mockTarget.On("Write", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		cancel()
+	}).Return(nil).Once()
+
+	_, err := indexer.Generate(ctx, "/root")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "interrupted after writing 1 index file(s)")
+
+	mockSource.AssertNotCalled(t, "Read", "/root/subdir")
+	mockTarget.AssertExpectations(t)
+}
+
+func TestGenerate_Timeout(t *testing.T) {
+	mockSource := new(MockSource)
+	mockTarget := new(MockSource)
+	indexer := Indexer{
+		Source: mockSource,
+		Target: mockTarget,
+		Cfg: Config{
+			Timeout:  time.Millisecond,
+			BasePath: "/root",
+		},
+	}
+
+	mockSource.On("Read", "/root").After(20*time.Millisecond).Return([]Item{{Name: "file.txt"}}, false, nil)
+
+	_, err := indexer.Generate(context.Background(), "/root")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "timeout of 1ms exceeded")
+
+	mockTarget.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
+
+func TestGenerate_KeepGoing(t *testing.T) {
+	newIndexer := func(keepGoing bool) (Indexer, *MockSource, *MockSource) {
+		mockSource := new(MockSource)
+		mockTarget := new(MockSource)
+
+		mockSource.On("Read", "/root").Return([]Item{
+			{Name: "bad", IsDir: true},
+			{Name: "good", IsDir: true},
+		}, false, nil)
+		mockSource.On("Read", "/root/bad").Return([]Item(nil), false, errors.New("permission denied"))
+		mockSource.On("Read", "/root/good").Return([]Item{{Name: "file.txt"}}, false, nil)
+		mockTarget.On("EnsureDirExists", mock.Anything).Return(nil)
+		mockTarget.On("Write", mock.Anything, mock.Anything).Return(nil)
+
+		return Indexer{
+			Source: mockSource,
+			Target: mockTarget,
+			Cfg: Config{
+				Recursive: true,
+				KeepGoing: keepGoing,
+				BasePath:  "/root",
+				SortBy:    "name",
+				Order:     "asc",
+			},
+		}, mockSource, mockTarget
+	}
+
+	// Without keep-going, the first failure stops the run
+	indexer, mockSource, _ := newIndexer(false)
+	_, err := indexer.Generate(context.Background(), "/root")
+	require.Error(t, err)
+	mockSource.AssertNotCalled(t, "Read", "/root/good")
+
+	// With keep-going, the failure is recorded and the rest is still written
+	indexer, _, mockTarget := newIndexer(true)
+	_, err = indexer.Generate(context.Background(), "/root")
+
+	var failedErr *FailedPathsError
+	require.ErrorAs(t, err, &failedErr)
+	require.Len(t, failedErr.Failures, 1)
+	assert.Equal(t, "/root/bad", failedErr.Failures[0].Path)
+	assert.Contains(t, err.Error(), "1 path(s) failed:\n  /root/bad: permission denied")
+
+	mockTarget.AssertCalled(t, "Write", mock.MatchedBy(func(data Data) bool {
+		return data.RelativePath == "/good"
+	}), mock.Anything)
+	mockTarget.AssertNumberOfCalls(t, "Write", 2)
+}
+
+func TestGenerate_Result(t *testing.T) {
+	sourceDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	files := map[string]string{
+		"file1.txt":              "content1",
+		"subdir/file2.txt":       "content2",
+		"private/.noindex":       "",
+		"private/secret.txt":     "secret",
+		"external/.skipindex":    "",
+		"external/external.html": "external",
+		"empty/.keep":            "",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(sourceDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0o755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0o644))
+	}
+
+	cfg := Config{
+		Source:         sourceDir,
+		Target:         targetDir,
+		Recursive:      true,
+		SortBy:         "name",
+		Order:          "asc",
+		IndexFile:      "index.html",
+		BasePath:       sourceDir,
+		DateFormat:     "2006-01-02",
+		NoIndexFiles:   []string{".noindex"},
+		SkipIndexFiles: []string{".skipindex"},
+		Skips:          []string{".keep"},
+	}
+	indexer := Indexer{
+		Cfg:    cfg,
+		Source: &LocalBackend{path: sourceDir, cfg: cfg},
+		Target: &LocalBackend{path: targetDir, cfg: cfg},
+	}
+
+	result, err := indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+
+	// The root, subdir, external and empty directories are listed. private is
+	// left out of the root listing because of its noindex file.
+	assert.Equal(t, 4, result.DirsScanned)
+	assert.Equal(t, 2, result.IndexesWritten)
+	assert.Equal(t, 0, result.IndexesUnchanged)
+	assert.Equal(t, 2, result.IndexesSkipped)
+	assert.Equal(t, 1, result.NoIndexHits)
+	assert.Equal(t, 1, result.SkipIndexHits)
+	assert.Equal(t, 5, result.ItemsListed)
+	assert.Positive(t, result.BytesUploaded)
+	assert.Positive(t, result.Duration)
+
+	// Nothing changed, so a second run doesn't write anything
+	result, err = indexer.Generate(context.Background(), sourceDir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.IndexesWritten)
+	assert.Equal(t, 2, result.IndexesUnchanged)
+	assert.Zero(t, result.BytesUploaded)
+}
+
+// themes are the built-in themes, which every feature of the listings is
+// tested with.
+var themes = []string{"default", "solarized", "nord", "dracula"}
+
+// testConfig returns the configuration of a run indexing sourceDir into
+// targetDir, which may be the same directory.
+func testConfig(sourceDir, targetDir string) Config {
+	return Config{
+		Source:     sourceDir,
+		Target:     targetDir,
+		SortBy:     "name",
+		Order:      "asc",
+		IndexFile:  "index.html",
+		BasePath:   sourceDir,
+		DateFormat: "2006-01-02",
+	}
+}
+
+// renderAllThemes generates the indexes of cfg.Source into cfg.Target with
+// each built-in theme, and calls check with the subtest of the theme and the
+// root index.html.
+func renderAllThemes(t *testing.T, cfg Config, check func(t *testing.T, index string)) {
+	t.Helper()
+
+	for _, theme := range themes {
+		t.Run(theme, func(t *testing.T) {
+			cfg := cfg
+			cfg.Theme = theme
+			indexer := Indexer{
+				Cfg:    cfg,
+				Source: &LocalBackend{path: cfg.Source, cfg: cfg},
+				Target: &LocalBackend{path: cfg.Target, cfg: cfg},
+			}
+
+			_, err := indexer.Generate(context.Background(), cfg.Source)
+			require.NoError(t, err)
+
+			index, err := os.ReadFile(filepath.Join(cfg.Target, cfg.IndexFile))
+			require.NoError(t, err)
+			check(t, string(index))
+		})
+	}
+}
+
+// renderDataAllThemes renders data as an HTML index with each built-in theme,
+// and calls check with the subtest of the theme and the page.
+func renderDataAllThemes(t *testing.T, data Data, check func(t *testing.T, content string)) {
+	t.Helper()
+
+	for _, theme := range themes {
+		t.Run(theme, func(t *testing.T) {
+			indexer := Indexer{Cfg: Config{Theme: theme}}
+			content, err := indexer.render(output{format: FormatHTML}, data)
+			require.NoError(t, err)
+			check(t, string(content))
+		})
+	}
+}
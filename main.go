@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/joshbeard/web-indexer/internal/webindexer"
@@ -87,8 +91,23 @@ func run(args []string) error {
 		return fmt.Errorf("unable to create indexer: %w", err)
 	}
 
+	// Cancel on SIGINT/SIGTERM so in-flight writes can finish. A second
+	// signal terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
 	log.Infof("Generating index for %s", cfg.Source)
-	err = indexer.Generate(indexer.Cfg.BasePath)
+	result, err := indexer.Generate(ctx, indexer.Cfg.BasePath)
+
+	// Write the report even if generation failed, so that it includes the
+	// errors.
+	if cfg.Report != "" {
+		if reportErr := result.WriteReport(cfg.Report); reportErr != nil {
+			log.Errorf("Unable to write report: %v", reportErr)
+		}
+	}
+
 	if err != nil {
 		return fmt.Errorf("unable to generate index: %w", err)
 	}
@@ -125,12 +144,50 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&cfg.CfgFile, "config", "c", "", "config file")
 	rootCmd.Flags().StringVarP(&cfg.BaseURL, "base-url", "u", "", "A URL to prepend to the links")
+	rootCmd.Flags().StringVarP(&cfg.ChecksumCache, "checksum-cache", "", "", "A local file to cache checksums in, "+
+		"so that files with the same size and modification time aren't hashed again")
+	rootCmd.Flags().BoolVarP(&cfg.ChecksumFiles, "checksum-files", "", false, "Write a checksum file such as "+
+		"SHA256SUMS to each directory. Requires --checksums")
+	rootCmd.Flags().StringSliceVarP(&cfg.Checksums, "checksums", "", []string{}, "Compute checksums of every "+
+		"file and show them in the listings. One or more of: md5, sha1, sha256, sha512")
+	rootCmd.Flags().StringSliceVarP(&cfg.Companions, "companions", "", []string{}, "Suffixes of files to show "+
+		"in the row of the file they accompany, such as .sha256,.asc,.sig")
 	rootCmd.Flags().StringVarP(&cfg.DateFormat, "date-format", "", "2006-01-02 15:04:05 MST", "The date format to use in the index page")
+	rootCmd.Flags().BoolVarP(&cfg.Descriptions, "descriptions", "", false, "Describe items from a .descriptions "+
+		"file in each directory or <name>.meta.yml sidecar files, which are hidden from the listing")
+	rootCmd.Flags().BoolVarP(&cfg.DetailPages, "detail-pages", "", false, "Write a detail page for each file, "+
+		"such as .details/foo.tar.gz.html, with its full metadata and a preview of small text files")
+	rootCmd.Flags().BoolVarP(&cfg.DirStats, "dir-stats", "", false, "Show the total size, file count and newest "+
+		"modification time of directories, recursively. Without --recursive, this lists the whole tree below the "+
+		"indexed directory")
 	rootCmd.Flags().BoolVarP(&cfg.DirsFirst, "dirs-first", "", true, "List directories first")
+	rootCmd.Flags().StringVarP(&cfg.Feed, "feed", "", "", "Write a feed of the most recently modified files. "+
+		"One of: atom, rss, json. Requires --base-url")
+	rootCmd.Flags().StringVarP(&cfg.FeedFile, "feed-file", "", "", "The name of the feed file. "+
+		"Defaults to atom.xml, rss.xml or feed.json")
+	rootCmd.Flags().IntVarP(&cfg.FeedItems, "feed-items", "", 20, "The number of files to include in the feed")
+	rootCmd.Flags().StringVarP(&cfg.FeedScope, "feed-scope", "", "tree", "Write a feed for the whole tree "+
+		"to its root, or one for each directory. One of: tree, directory")
+	rootCmd.Flags().StringSliceVarP(&cfg.Formats, "formats", "", []string{}, "The listing formats to write "+
+		"for each directory. One or more of: html, json, md. Defaults to html")
+	rootCmd.Flags().BoolVarP(&cfg.Gallery, "gallery", "", false, "Show listings as a grid of thumbnails, "+
+		"generated for JPEG, PNG, GIF and WebP images into a hidden .thumbnails directory")
+	rootCmd.Flags().StringSliceVarP(&cfg.HeaderFiles, "header-files", "", []string{}, "Files to render above "+
+		"the listing of a directory, the first one found is used (e.g. HEADER.md,HEADER.html)")
 	rootCmd.Flags().StringVarP(&cfg.IndexFile, "index-file", "i", "index.html", "The name of the index file")
+	rootCmd.Flags().BoolVarP(&cfg.KeepGoing, "keep-going", "k", false, "Continue when a directory fails and report "+
+		"all failed paths at the end")
+	rootCmd.Flags().StringVarP(&cfg.Layout, "layout", "", "detailed", "The default layout of the listings, which "+
+		"visitors can switch. One of: detailed, compact, grid")
 	rootCmd.Flags().BoolVarP(&cfg.LinkToIndexes, "link-to-index", "l", false, "Link to the index file or just the path")
 	rootCmd.Flags().StringVarP(&cfg.LogLevel, "log-level", "L", "info", "The log level")
 	rootCmd.Flags().StringVarP(&cfg.LogFile, "log-file", "F", "", "The log file")
+	rootCmd.Flags().IntVarP(&cfg.MaxRetries, "max-retries", "", 3, "The number of times to retry a backend request "+
+		"that failed with a transient error, such as S3 throttling")
+	rootCmd.Flags().BoolVarP(&cfg.MediaMetadata, "media-metadata", "", false, "Read the duration and "+
+		"resolution of media files from their MP4, QuickTime, WAV or FLAC headers. Requires --media-players")
+	rootCmd.Flags().BoolVarP(&cfg.MediaPlayers, "media-players", "", false, "Show inline players for audio "+
+		"and video files, detected by their MIME type")
 	rootCmd.Flags().BoolVarP(&cfg.Minify, "minify", "m", false, "Minify the index page")
 	rootCmd.Flags().StringSliceVarP(&cfg.NoIndexFiles, "noindex-files", "n", []string{".noindex"}, "A list of files that indicate a directory should be skipped. "+
 		"Comma separated or specified multiple times")
@@ -138,15 +195,43 @@ func init() {
 		"Comma separated or specified multiple times")
 	rootCmd.Flags().BoolVarP(&cfg.Quiet, "quiet", "q", false, "Suppress log output")
 	rootCmd.Flags().StringVarP(&cfg.Order, "order", "", "asc", "The order for the items. One of: asc, desc")
+	rootCmd.Flags().IntVarP(&cfg.PageSize, "page-size", "", 0, "Split HTML listings into pages of this many "+
+		"items. 0 disables pagination")
+	rootCmd.Flags().StringVarP(&cfg.Report, "report", "", "", "Write a JSON summary of the run to this local file")
+	rootCmd.Flags().StringSliceVarP(&cfg.ReadmeFiles, "readme-files", "", []string{}, "Files to render below "+
+		"the listing of a directory, the first one found is used (e.g. README.md,FOOTER.txt)")
 	rootCmd.Flags().BoolVarP(&cfg.Recursive, "recursive", "r", false, "List files recursively")
+	rootCmd.Flags().DurationVarP(&cfg.RequestTimeout, "request-timeout", "", 0, "The timeout for each backend request, "+
+		"such as listing or uploading to S3 (e.g. 30s). 0 disables the timeout")
+	rootCmd.Flags().DurationVarP(&cfg.RetryDelay, "retry-delay", "", 500*time.Millisecond, "The base delay between "+
+		"retries. It doubles with each retry, with random jitter")
+	rootCmd.Flags().BoolVarP(&cfg.Search, "search", "", false, "Write a search index of all paths to the root "+
+		"of the target and add a search box to the built-in themes")
+	rootCmd.Flags().BoolVarP(&cfg.Sitemap, "sitemap", "", false, "Write a sitemap.xml of the index pages to the "+
+		"root of the target. Requires --base-url")
+	rootCmd.Flags().BoolVarP(&cfg.SitemapFiles, "sitemap-files", "", false, "Include every file in the sitemap")
 	rootCmd.Flags().StringSliceVarP(&cfg.Skips, "skip", "S", []string{}, "A list of files or directories to skip. "+
 		"Comma separated or specified multiple times")
+	rootCmd.Flags().BoolVarP(&cfg.SniffContent, "sniff-content", "", false, "Detect the MIME type of files without "+
+		"a known extension from their content, or from the Content-Type of S3 objects")
 	rootCmd.Flags().StringVarP(&cfg.SortBy, "sort-by", "", "natural_name", "The order for the index page. One of: last_modified, name, natural_name")
 	rootCmd.Flags().StringVarP(&cfg.Source, "source", "s", "", "REQUIRED. The source directory or S3 URI to list")
 	rootCmd.Flags().StringVarP(&cfg.Target, "target", "t", "", "REQUIRED. The target directory or S3 URI to write to")
 	rootCmd.Flags().StringVarP(&cfg.Template, "template", "f", "", "A custom template file to use for the index page")
 	rootCmd.Flags().StringVarP(&cfg.Theme, "theme", "", "default", "The theme to use for the index page. One of: default, solarized, nord, dracula")
+	rootCmd.Flags().IntVarP(&cfg.ThumbnailSize, "thumbnail-size", "", 256, "The largest width and height of "+
+		"gallery thumbnails in pixels")
+	rootCmd.Flags().DurationVarP(&cfg.Timeout, "timeout", "", 0, "The maximum duration of the whole run (e.g. 10m). "+
+		"0 disables the timeout")
 	rootCmd.Flags().StringVarP(&cfg.Title, "title", "T", "", "The title of the index page")
+	rootCmd.Flags().BoolVarP(&cfg.Tree, "tree", "", false, "Write a single index page for the root listing "+
+		"the whole tree, with collapsible directories")
+	rootCmd.Flags().BoolVarP(&cfg.VerifyCompanions, "verify-companions", "", false, "Verify files against "+
+		"the checksums in their companion checksum files, such as .sha256. Requires --checksums")
+	rootCmd.Flags().BoolVarP(&cfg.Viewer, "viewer", "", false, "Write a syntax highlighted viewer page for "+
+		"each text file, such as .viewer/run.sh.html, with its language detected by extension")
+	rootCmd.Flags().Int64VarP(&cfg.ViewerMaxSize, "viewer-max-size", "", 1<<20, "The largest file in bytes "+
+		"to write a viewer page for")
 
 	err := viper.BindPFlags(rootCmd.Flags())
 	cobra.CheckErr(err)